@@ -0,0 +1,570 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Extensions of version archives, e.g. v1.2.3.tar.gz for the v1.2.3 version directory
+var archiveExtensions = []string{".tar.gz", ".tgz", ".zip"}
+
+// File or directory of an archive, implements fs.FileInfo and fs.DirEntry
+type archiveEntry struct {
+	name     string
+	size     int64
+	mode     fs.FileMode
+	modTime  time.Time
+	children []*archiveEntry // Directory entries ordered by name
+	zipFile  *zip.File       // Entry of the zip archive
+	offset   int64           // Offset of the file data in the decompressed tar archive
+}
+
+func (e *archiveEntry) Name() string               { return e.name }
+func (e *archiveEntry) Size() int64                { return e.size }
+func (e *archiveEntry) Mode() fs.FileMode          { return e.mode }
+func (e *archiveEntry) Type() fs.FileMode          { return e.mode.Type() }
+func (e *archiveEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e *archiveEntry) ModTime() time.Time         { return e.modTime }
+func (e *archiveEntry) IsDir() bool                { return e.mode.IsDir() }
+func (e *archiveEntry) Sys() interface{}           { return nil }
+
+// How long a changed archive is kept open, so the requests being served can finish reading it
+const archiveCloseDelay = time.Minute
+
+// Read-only file system of a tar.gz or zip archive. The index of the archive is built when the archive is opened,
+// files are streamed from the archive when they are read.
+// A tar.gz archive is extracted to a temporary file on the first read, so its files can be read from the middle.
+// If the archive can't be extracted, its files are decompressed from the start of the archive.
+type archiveFS struct {
+	source  fs.FS  // File system with the archive
+	name    string // Path of the archive in the source file system
+	size    int64
+	modTime time.Time
+	zipFile fs.File                  // The open zip archive
+	zipData io.ReaderAt              // Data of the zip archive
+	entries map[string]*archiveEntry // Entries by the path, e.g. cli/index.html
+
+	mutex       sync.Mutex
+	extractable bool     // Whether the tar.gz archive can be extracted to a temporary file
+	tarFile     *os.File // The extracted tar archive, removed when the archive is closed
+}
+
+// Open the archive and build its index. Only the archives which are closed later can be extracted.
+func openArchiveFS(source fs.FS, name string, extractable bool) (*archiveFS, error) {
+	info, err := fs.Stat(source, name)
+	if err != nil {
+		return nil, err
+	}
+	result := &archiveFS{
+		source:  source,
+		name:    name,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		entries: map[string]*archiveEntry{".": {name: ".", mode: fs.ModeDir | 0555, modTime: info.ModTime()}},
+
+		extractable: extractable,
+	}
+
+	if strings.HasSuffix(name, ".zip") {
+		err = result.indexZip()
+	} else {
+		err = result.indexTarGz()
+	}
+	if err != nil {
+		result.Close()
+		return nil, fmt.Errorf("can't index the %s archive (%s)", name, err.Error())
+	}
+
+	for _, entry := range result.entries {
+		sort.Slice(entry.children, func(i, j int) bool {
+			return entry.children[i].name < entry.children[j].name
+		})
+	}
+	log.Debugln(fmt.Sprintf("Indexed the %s archive (%d entries)", name, len(result.entries)))
+	return result, nil
+}
+
+func (a *archiveFS) indexZip() error {
+	file, err := a.source.Open(a.name)
+	if err != nil {
+		return err
+	}
+	a.zipFile = file
+	// Files of the OS file system are read directly, files of other file systems are read to memory
+	readerAt, ok := file.(io.ReaderAt)
+	if !ok {
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		readerAt = bytes.NewReader(data)
+	}
+	a.zipData = readerAt
+	reader, err := zip.NewReader(readerAt, a.size)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		entry := &archiveEntry{size: int64(file.UncompressedSize64), mode: file.Mode(), modTime: file.Modified, zipFile: file}
+		if strings.HasSuffix(file.Name, "/") {
+			entry.mode, entry.zipFile = fs.ModeDir|0555, nil
+		}
+		a.addEntry(file.Name, entry)
+	}
+	return nil
+}
+
+func (a *archiveFS) indexTarGz() error {
+	file, err := a.source.Open(a.name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	counter := &countingReader{reader: gz}
+	tr := tar.NewReader(counter)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			a.addEntry(header.Name, &archiveEntry{mode: fs.ModeDir | 0555, modTime: header.ModTime})
+		case tar.TypeReg:
+			a.addEntry(header.Name, &archiveEntry{size: header.Size, mode: 0444, modTime: header.ModTime, offset: counter.count})
+		}
+	}
+}
+
+// Add the entry and its parent directories to the index. Entries can't be outside of the archive root.
+func (a *archiveFS) addEntry(name string, entry *archiveEntry) {
+	name = toFSPath(name)
+	if name == "." {
+		return
+	}
+	if existing, ok := a.entries[name]; ok {
+		// The directory was added as a parent of another entry
+		entry.children = existing.children
+		*existing = *entry
+		existing.name = path.Base(name)
+		return
+	}
+	entry.name = path.Base(name)
+	a.entries[name] = entry
+
+	parentName := path.Dir(name)
+	parent, ok := a.entries[parentName]
+	if !ok {
+		parent = &archiveEntry{mode: fs.ModeDir | 0555, modTime: a.modTime}
+		a.addEntry(parentName, parent)
+	}
+	parent.children = append(parent.children, entry)
+}
+
+// Get the reader of the file content. Files stored in the archive without compression are read directly.
+func (a *archiveFS) openEntry(entry *archiveEntry) (io.ReadSeeker, error) {
+	if entry.zipFile == nil {
+		if tarFile := a.getTarFile(); tarFile != nil {
+			return io.NewSectionReader(tarFile, entry.offset, entry.size), nil
+		}
+		return &streamReader{size: entry.size, open: func() (io.ReadCloser, error) { return a.openTarEntry(entry) }}, nil
+	}
+	if entry.zipFile.Method == zip.Store {
+		offset, err := entry.zipFile.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		return io.NewSectionReader(a.zipData, offset, entry.size), nil
+	}
+	return &streamReader{size: entry.size, open: entry.zipFile.Open}, nil
+}
+
+// Get the extracted tar archive, the archive is extracted on the first call. Returns nil if the archive can't be extracted.
+func (a *archiveFS) getTarFile() *os.File {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.tarFile == nil && a.extractable {
+		tarFile, err := a.extractTar()
+		if err != nil {
+			log.Errorf("Can't extract the %s archive, its files are decompressed from the start (%s)", a.name, err.Error())
+			a.extractable = false
+		}
+		a.tarFile = tarFile
+	}
+	return a.tarFile
+}
+
+// Decompress the tar.gz archive to a temporary file
+func (a *archiveFS) extractTar() (*os.File, error) {
+	file, err := a.source.Open(a.name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	tarFile, err := ioutil.TempFile("", "v-router-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tarFile, gz); err != nil {
+		_ = tarFile.Close()
+		_ = os.Remove(tarFile.Name())
+		return nil, err
+	}
+	log.Debugln(fmt.Sprintf("Extracted the %s archive to %s", a.name, tarFile.Name()))
+	return tarFile, nil
+}
+
+// Open the file of the tar.gz archive without extracting the archive, the data before the file is skipped
+func (a *archiveFS) openTarEntry(entry *archiveEntry) (io.ReadCloser, error) {
+	file, err := a.source.Open(a.name)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err == nil {
+		_, err = io.CopyN(ioutil.Discard, gz, entry.offset)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(gz, entry.size), file}, nil
+}
+
+// Open the file, implements fs.FS
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &archiveFile{archive: a, entry: entry}, nil
+}
+
+// Close the archive and remove the extracted tar archive
+func (a *archiveFS) Close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.zipFile != nil {
+		_ = a.zipFile.Close()
+	}
+	if a.tarFile != nil {
+		_ = a.tarFile.Close()
+		_ = os.Remove(a.tarFile.Name())
+		a.tarFile = nil
+	}
+	a.extractable = false
+}
+
+// Open file of an archive. The content is opened on the first Read or Seek call.
+type archiveFile struct {
+	archive *archiveFS
+	entry   *archiveEntry
+	reader  io.ReadSeeker
+	dirPos  int
+}
+
+func (f *archiveFile) load() error {
+	if f.entry.IsDir() {
+		return fmt.Errorf("%s is a directory", f.entry.name)
+	}
+	if f.reader == nil {
+		reader, err := f.archive.openEntry(f.entry)
+		if err != nil {
+			return err
+		}
+		f.reader = reader
+	}
+	return nil
+}
+
+func (f *archiveFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Read(p)
+}
+
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+// Read the directory entries, implements fs.ReadDirFile
+func (f *archiveFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !f.entry.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", f.entry.name)
+	}
+	children := f.entry.children[f.dirPos:]
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+		if count < len(children) {
+			children = children[:count]
+		}
+	}
+	f.dirPos += len(children)
+
+	result := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		result = append(result, child)
+	}
+	return result, nil
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+	return f.entry, nil
+}
+
+func (f *archiveFile) Close() error {
+	if closer, ok := f.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Seekable reader of a compressed file. The file is decompressed while it is read,
+// seeking forward skips the data and seeking back decompresses the file again.
+type streamReader struct {
+	open    func() (io.ReadCloser, error)
+	size    int64
+	reader  io.ReadCloser
+	readPos int64 // Position of the decompressed data
+	pos     int64 // Position set by Seek
+}
+
+func (z *streamReader) Read(p []byte) (int, error) {
+	if z.pos >= z.size {
+		return 0, io.EOF
+	}
+	if z.reader == nil || z.pos < z.readPos {
+		if err := z.Close(); err != nil {
+			return 0, err
+		}
+		reader, err := z.open()
+		if err != nil {
+			return 0, err
+		}
+		z.reader, z.readPos = reader, 0
+	}
+	if z.pos > z.readPos {
+		if _, err := io.CopyN(ioutil.Discard, z.reader, z.pos-z.readPos); err != nil {
+			return 0, err
+		}
+		z.readPos = z.pos
+	}
+	n, err := z.reader.Read(p)
+	z.readPos += int64(n)
+	z.pos = z.readPos
+	return n, err
+}
+
+func (z *streamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += z.pos
+	case io.SeekEnd:
+		offset += z.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	z.pos = offset
+	return offset, nil
+}
+
+func (z *streamReader) Close() error {
+	if z.reader == nil {
+		return nil
+	}
+	err := z.reader.Close()
+	z.reader = nil
+	return err
+}
+
+// Counts bytes read from the reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// Archive in a file system
+type archiveKey struct {
+	source fs.FS
+	name   string
+}
+
+// Opened archives. An archive is opened again if its size or modification time changes.
+var (
+	archivesMutex sync.Mutex
+	archives      = map[archiveKey]*archiveFS{}
+)
+
+// Get the file system of the archive
+func getArchiveFS(source fs.FS, name string) (*archiveFS, error) {
+	// Archives of file systems which can't be compared (e.g. fstest.MapFS) aren't cached
+	if !reflect.TypeOf(source).Comparable() {
+		return openArchiveFS(source, name, false)
+	}
+
+	info, err := fs.Stat(source, name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := archiveKey{source: source, name: name}
+	archivesMutex.Lock()
+	archive, ok := archives[key]
+	archivesMutex.Unlock()
+	if ok && archive.size == info.Size() && archive.modTime.Equal(info.ModTime()) {
+		return archive, nil
+	}
+
+	// The archive is indexed without the lock, so requests to other archives are not delayed
+	archive, err = openArchiveFS(source, name, true)
+	if err != nil {
+		return nil, err
+	}
+	archivesMutex.Lock()
+	defer archivesMutex.Unlock()
+	if previous, ok := archives[key]; ok {
+		if previous.size == archive.size && previous.modTime.Equal(archive.modTime) {
+			// The archive was indexed by a concurrent request
+			archive.Close()
+			return previous, nil
+		}
+		log.Infoln(fmt.Sprintf("The %s archive has changed, reindexed", name))
+		time.AfterFunc(archiveCloseDelay, previous.Close)
+	}
+	archives[key] = archive
+	return archive, nil
+}
+
+// Close the opened archives, removing the extracted tar archives
+func closeArchives() {
+	archivesMutex.Lock()
+	defer archivesMutex.Unlock()
+	for key, archive := range archives {
+		archive.Close()
+		delete(archives, key)
+	}
+}
+
+// Get the file system of the version directory, or of the version archive if the directory is absent.
+// With static overlays, the version directories and archives of all the layers are overlaid.
+func getVersionFileSystem(fsys fs.FS, dir string) (fs.FS, error) {
+	if overlay, ok := fsys.(overlayFS); ok {
+		var result overlayFS
+		for _, layer := range overlay {
+			if !hasVersionFiles(layer.fsys, dir) {
+				continue
+			}
+			versionFS, err := getVersionFileSystem(layer.fsys, dir)
+			if err != nil {
+				return nil, err
+			}
+			name := path.Join(layer.name, dir)
+			if archive, ok := versionFS.(*archiveFS); ok {
+				name = path.Join(layer.name, archive.name)
+			}
+			result = append(result, staticLayer{name: name, fsys: versionFS})
+		}
+		return result, nil
+	}
+
+	if !isFSDir(fsys, dir) {
+		for _, extension := range archiveExtensions {
+			if _, err := fs.Stat(fsys, dir+extension); err == nil {
+				return getArchiveFS(fsys, dir+extension)
+			}
+		}
+	}
+	return fs.Sub(fsys, dir)
+}
+
+// Check whether the file system has the version directory or the version archive
+func hasVersionFiles(fsys fs.FS, dir string) bool {
+	if isFSDir(fsys, dir) {
+		return true
+	}
+	for _, extension := range archiveExtensions {
+		if _, err := fs.Stat(fsys, dir+extension); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Build indexes of the archives of known versions, so the first requests to versions are not delayed
+func indexVersionArchives() {
+	for _, product := range Products {
+		releases := product.getReleasesStatus()
+		for _, version := range getAllVersions(releases) {
+			for _, lang := range GlobalConfig.Languages {
+				dir, err := getVersionFilesPath(product, version, lang)
+				if err != nil {
+					log.Errorf("Can't get the directory of version %s: %s", version, err.Error())
+					continue
+				}
+				if _, err := getVersionFileSystem(getStaticFS(), dir); err != nil {
+					log.Errorln(err.Error())
+				}
+			}
+		}
+	}
+}
+
+// Get all the versions of channels
+func getAllVersions(releases *ReleasesStatusType) (versions []string) {
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			if !contains(versions, channel.Version) {
+				versions = append(versions, channel.Version)
+			}
+		}
+	}
+	return
+}
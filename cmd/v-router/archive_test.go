@@ -0,0 +1,236 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testArchiveFiles = map[string]string{
+	"index.html":     "<html>index</html>",
+	"cli/index.html": "<html>cli</html>",
+	"css/site.css":   "body { color: black; }",
+	"empty/.keep":    "",
+}
+
+func writeTestZip(t *testing.T, archivePath string) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, content := range testArchiveFiles {
+		// Stylesheets are stored without compression, other files are deflated
+		method := zip.Deflate
+		if filepath.Ext(name) == ".css" {
+			method = zip.Store
+		}
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTarGz(t *testing.T, archivePath string) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	if err := writer.WriteHeader(&tar.Header{Name: "./cli/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testArchiveFiles {
+		if err := writer.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveFS(t *testing.T) {
+	root, err := ioutil.TempDir("", "v-router-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeTestZip(t, filepath.Join(root, "docs.zip"))
+	writeTestTarGz(t, filepath.Join(root, "docs.tar.gz"))
+
+	for _, archiveName := range []string{"docs.zip", "docs.tar.gz"} {
+		archive, err := getArchiveFS(os.DirFS(root), archiveName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range testArchiveFiles {
+			file, err := archive.Open(name)
+			if err != nil {
+				t.Errorf("%s: can't open %s: %v", archiveName, name, err)
+				continue
+			}
+			data, err := ioutil.ReadAll(file)
+			if err != nil || string(data) != content {
+				t.Errorf("%s: wrong content of %s: %q (%v)", archiveName, name, string(data), err)
+			}
+
+			// Files are read from the middle for range requests, and read again after seeking back
+			seeker := file.(io.Seeker)
+			for _, offset := range []int64{int64(len(content) / 2), 0} {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					t.Errorf("%s: can't seek %s: %v", archiveName, name, err)
+				}
+				if data, err := ioutil.ReadAll(file); err != nil || string(data) != content[offset:] {
+					t.Errorf("%s: wrong content of %s from %d: %q (%v)", archiveName, name, offset, string(data), err)
+				}
+			}
+			if size, err := seeker.Seek(0, io.SeekEnd); err != nil || size != int64(len(content)) {
+				t.Errorf("%s: wrong size of %s: %d (%v)", archiveName, name, size, err)
+			}
+			_ = file.Close()
+		}
+
+		dir, err := archive.Open(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if len(names) != 4 || names[0] != "cli" || names[3] != "index.html" || !entries[0].IsDir() {
+			t.Errorf("%s: wrong root directory entries %v", archiveName, names)
+		}
+
+		if _, err := archive.Open("../docs.zip"); err == nil {
+			t.Errorf("%s: paths outside of the archive must not be opened", archiveName)
+		}
+		if cached, _ := getArchiveFS(os.DirFS(root), archiveName); cached != archive {
+			t.Errorf("%s: the archive must be indexed once", archiveName)
+		}
+	}
+
+	// The tar.gz archive is extracted on the first read, the extracted file is removed when the archive is closed
+	archive, err := getArchiveFS(os.DirFS(root), "docs.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarFile := archive.getTarFile()
+	if tarFile == nil {
+		t.Fatal("the tar.gz archive is not extracted")
+	}
+	closeArchives()
+	if _, err := os.Stat(tarFile.Name()); !os.IsNotExist(err) {
+		t.Errorf("the extracted archive %s is not removed: %v", tarFile.Name(), err)
+	}
+
+	// Archives which can't be extracted are decompressed from the start
+	archive, err = openArchiveFS(os.DirFS(root), "docs.tar.gz", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testArchiveFiles {
+		data, err := fs.ReadFile(archive, name)
+		if err != nil || string(data) != content {
+			t.Errorf("wrong content of %s without extracting: %q (%v)", name, string(data), err)
+		}
+	}
+	if archive.getTarFile() != nil {
+		t.Error("the archive must not be extracted")
+	}
+}
+
+func TestStandaloneArchives(t *testing.T) {
+	root, err := ioutil.TempDir("", "v-router-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeTestZip(t, filepath.Join(root, "v1.1.5.zip"))
+	writeTestTarGz(t, filepath.Join(root, "v1.2.0.tar.gz"))
+
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.PathStatic = root
+	GlobalConfig.Standalone = true
+
+	newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.2.0"}}},
+	}})
+	router := newRouter()
+
+	for _, version := range []string{"v1.1.5", "v1.2.0"} {
+		tests := []struct {
+			path        string
+			code        int
+			body        string
+			contentType string
+		}{
+			{"/documentation/" + version + "/", http.StatusOK, "<html>index</html>", "text/html; charset=utf-8"},
+			{"/documentation/" + version + "/cli/", http.StatusOK, "<html>cli</html>", "text/html; charset=utf-8"},
+			{"/documentation/" + version + "/cli", http.StatusMovedPermanently, "", ""},
+			{"/documentation/" + version + "/cli/index.html", http.StatusMovedPermanently, "", ""},
+			{"/documentation/" + version + "/css/site.css", http.StatusOK, "body { color: black; }", "text/css; charset=utf-8"},
+			{"/documentation/" + version + "/empty/", http.StatusNotFound, "", ""},
+			{"/documentation/" + version + "/missing.html", http.StatusNotFound, "", ""},
+		}
+		for _, test := range tests {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+			if recorder.Code != test.code {
+				t.Errorf("%s: expected %d, got %d", test.path, test.code, recorder.Code)
+			}
+			if test.body != "" && recorder.Body.String() != test.body {
+				t.Errorf("%s: wrong body %q", test.path, recorder.Body.String())
+			}
+			if test.contentType != "" && recorder.Header().Get("Content-Type") != test.contentType {
+				t.Errorf("%s: wrong content type %s", test.path, recorder.Header().Get("Content-Type"))
+			}
+		}
+
+		// Range requests
+		request := httptest.NewRequest("GET", "/documentation/"+version+"/css/site.css", nil)
+		request.Header.Set("Range", "bytes=0-3")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusPartialContent || recorder.Body.String() != "body" {
+			t.Errorf("%s: wrong range response %d %q", version, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Group URLs are served from the archive of the group version
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/documentation/v1/cli/", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "<html>cli</html>" {
+		t.Errorf("Wrong group response %d %q", recorder.Code, recorder.Body.String())
+	}
+}
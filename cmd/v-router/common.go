@@ -15,48 +15,92 @@ import (
 	"os"
 	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
 
 type GlobalConfigType struct {
-	DefaultGroup      string `default:"v1" split_words:"true"`
-	DefaultChannel    string `default:"stable" split_words:"true"`
-	ShowLatestChannel bool   `default:"false" split_words:"true"`
-	ListenAddress     string `default:"0.0.0.0" split_words:"true"`
-	ListenPort        string `default:"8080" split_words:"true"`
-	LogLevel          string `default:"warn" split_words:"true"`
-	LogFormat         string `default:"text" split_words:"true"`
-	PathChannelsFile  string `default:"channels.yaml" split_words:"true"`
-	PathStatic        string `default:"root" split_words:"true"`
-	PathTpls          string `default:"/includes" split_words:"true"`
-	LocationVersions  string `default:"/documentation" split_words:"true"`
-	I18nType          string `default:"domain" split_words:"true"`
-	UrlValidation     bool   `default:"false" split_words:"true"`
-	DomainMap         string `default:"" split_words:"true"`
+	DefaultGroup           string        `default:"v1" split_words:"true"`
+	DefaultChannel         string        `default:"stable" split_words:"true"`
+	Channels               []string      `default:"rock-solid,stable,ea,beta,alpha" split_words:"true"`
+	FallbackChannels       []string      `default:"" split_words:"true"`
+	ShowLatestChannel      bool          `default:"false" split_words:"true"`
+	ChannelURLGroup        string        `default:"newest" split_words:"true"`
+	ListenAddress          string        `default:"0.0.0.0" split_words:"true"`
+	ListenPort             string        `default:"8080" split_words:"true"`
+	LogLevel               string        `default:"warn" split_words:"true"`
+	LogFormat              string        `default:"text" split_words:"true"`
+	PathChannelsFile       string        `default:"channels.yaml" split_words:"true"`
+	ChannelsReloadInterval time.Duration `default:"5s" split_words:"true"`
+	ChannelsData           string        `default:"" split_words:"true"`
+	PathProductsFile       string        `default:"" split_words:"true"`
+	PathStatic             string        `default:"root" split_words:"true"`
+	PathStaticOverlays     []string      `default:"" split_words:"true"`
+	PathTpls               string        `default:"/includes" split_words:"true"`
+	LocationVersions       string        `default:"/documentation" split_words:"true"`
+	I18nType               string        `default:"domain" split_words:"true"`
+	Languages              []string      `default:"en,ru" split_words:"true"`
+	DefaultLanguage        string        `default:"en" split_words:"true"`
+	LanguageCookie         string        `default:"lang" split_words:"true"`
+	VersionCookie          string        `default:"" split_words:"true"`
+	ProxyUpstream          string        `default:"" split_words:"true"`
+	ProxyTimeout           time.Duration `default:"30s" split_words:"true"`
+	Standalone             bool          `default:"false" split_words:"true"`
+	VersionsLayout         string        `default:"{{ .VersionURL }}" split_words:"true"`
+	UrlValidation          bool          `default:"false" split_words:"true"`
+	DomainMap              string        `default:"" split_words:"true"`
 }
 
 type ChannelType struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name     string               `json:"name"`
+	Version  string               `json:"version"`
+	History  []ChannelHistoryItem `json:"history,omitempty"`  // Previous versions of the channel
+	Upstream string               `json:"upstream,omitempty"` // URL of the server with the channel version documentation, used in the proxy mode
+}
+
+// Version promoted to a channel
+type ChannelHistoryItem struct {
+	Version  string    `json:"version"`
+	Promoted time.Time `json:"promoted"` // When the version was promoted to the channel
 }
 
 type ReleaseType struct {
-	Name     string
-	Channels []ChannelType
+	Name          string
+	Channels      []ChannelType
+	Deprecated    *DateType `json:"deprecated,omitempty"`                                   // When the group becomes deprecated
+	EOL           *DateType `json:"eol,omitempty"`                                          // When the group reaches its end of life
+	SupportStatus string    `json:"supportStatus,omitempty" yaml:"supportStatus,omitempty"` // supported, deprecated or eol
+	Replacement   string    `json:"replacement,omitempty"`                                  // Group recommended instead of the deprecated one
+	// Channels to choose the version from if the default channel is absent in the group. Overrides VROUTER_FALLBACK_CHANNELS if set.
+	FallbackChannels []string `json:"fallbackChannels,omitempty" yaml:"fallbackChannels,omitempty"`
+	DefaultChannel   string   `json:"defaultChannel,omitempty" yaml:"defaultChannel,omitempty"` // Overrides the default channel of the product for the group
+	Hidden           bool     `json:"hidden,omitempty"`                                         // The group is routable, but is not shown in menus
+	Title            string   `json:"title,omitempty"`                                          // Display title of the group, e.g. "1.2 LTS"
+	Badges           []string `json:"badges,omitempty"`                                         // Badges of the group, e.g. "recommended" or "new"
+	Weight           int      `json:"weight,omitempty"`                                         // Menu order, groups with lower weights go first
 }
 
 type ReleasesStatusType struct {
-	Groups []ReleaseType
+	Channels []string          // Channel names from the most stable to the least stable. Overrides VROUTER_CHANNELS if set.
+	Aliases  map[string]string `json:"aliases,omitempty" yaml:"aliases,omitempty"` // Alternative channel names, e.g. early-access: ea
+	Groups   []ReleaseType
 }
 
 type APIStatusResponseType struct {
-	Status         string        `json:"status"`
-	Msg            string        `json:"msg"`
-	RootVersion    string        `json:"rootVersion"`
-	RootVersionURL string        `json:"rootVersionURL"`
-	Releases       []ReleaseType `json:"releasechannels"`
+	Status         string                       `json:"status"`
+	Product        string                       `json:"product"`
+	Msg            string                       `json:"msg"`
+	RootVersion    string                       `json:"rootVersion"`
+	RootVersionURL string                       `json:"rootVersionURL"`
+	RootReason     string                       `json:"rootVersionReason,omitempty"` // Why the root version was chosen
+	GroupVersions  []versionResolutionType      `json:"groupVersions,omitempty"`     // Versions the group URLs (e.g. /v1.2/) lead to
+	NewestVersion  string                       `json:"newestVersion"`
+	Releases       []ReleaseType                `json:"releasechannels"`
+	LoadTime       *time.Time                   `json:"loadTime,omitempty"`      // When the served channels data was loaded
+	LastError      string                       `json:"lastError,omitempty"`     // Error of the last channels file load, if any
+	LastErrorTime  *time.Time                   `json:"lastErrorTime,omitempty"` // When the last channels file load error occurred
+	Sources        []channelSourceState         `json:"sources,omitempty"`
+	VersionsAt     map[string]map[string]string `json:"versionsAt,omitempty"` // Versions of channels by groups at the time from the 'date' query parameter
 }
 
 type templateDataType struct {
@@ -68,25 +112,43 @@ type templateDataType struct {
 	CurrentLang            string
 	AbsoluteVersion        string // Contains explicit version, used for getting git link to source file
 	CurrentVersionURL      string
-	CurrentPageURLRelative string // Relative URL, without "<lang>/<LocationVersions>/<version>"
-	CurrentPageURL         string // Full page URL
-	MenuDocumentationLink  string // E.g. Used for top menus
+	CurrentPageURLRelative string            // Relative URL, without "<lang>/<LocationVersions>/<version>"
+	CurrentPageURL         string            // Full page URL
+	MenuDocumentationLink  string            // E.g. Used for top menus
+	SupportStatus          string            // Support status of the current group (supported, deprecated or eol)
+	IsDeprecated           bool              // Whether the current group is deprecated or has reached its end of life
+	EOLDate                string            // End-of-life date of the current group (YYYY-MM-DD)
+	RecommendedVersion     *versionMenuItems // Version to use instead of the deprecated one
+	MenuGroups             []menuGroupType   // Hierarchical version menu: groups with versions and channels
+	RememberedGroup        string            // Group remembered in the version cookie, if any
+	RememberedChannel      string            // Channel remembered in the version cookie, if any
+	VersionResetURL        string            // URL resetting the remembered version. Empty if the version cookie is disabled.
 }
 
 type versionMenuItems struct {
-	Group      string
-	Channel    string
-	Version    string
-	VersionURL string // Base URL for corresponding version without a leading /, e.g. 'v1.2.3-plus-fix6'.
-	IsCurrent  bool
+	Group         string
+	Channel       string
+	Version       string
+	VersionURL    string // Base URL for corresponding version without a leading /, e.g. 'v1.2.3-plus-fix6'.
+	IsCurrent     bool
+	History       []ChannelHistoryItem // Versions previously promoted to the channel, the newest first
+	SupportStatus string               // Support status of the group (supported, deprecated or eol)
+	IsDeprecated  bool                 // Whether the group is deprecated or has reached its end of life, e.g. to grey it out
+	EOLDate       string               // End-of-life date of the group (YYYY-MM-DD)
+	Title         string               // Display title of the group, e.g. "1.2 LTS". The group name without the leading 'v' by default.
+	Badges        []string             // Badges of the group, e.g. "recommended" or "new"
 }
 
-var ReleasesStatus ReleasesStatusType
 var DomainMap map[string]string
 
-var channelsListReverseStability = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
 var i18nTypes = []string{"domain", "location", "separate-domain"}
 
+// Groups used to resolve group-less channel URLs like /documentation/stable/
+var channelURLGroups = []string{"newest", "default"}
+
+// Matches the version URL of a group channel, e.g. v1.2-ea
+var groupChannelURLRegexp = regexp.MustCompile(`^(v[0-9]+(?:\.[0-9]+)?)-(.+)$`)
+
 // Сhecks if a string is present in a slice.
 func contains(s []string, str string) bool {
 	for _, v := range s {
@@ -98,32 +160,34 @@ func contains(s []string, str string) bool {
 	return false
 }
 
-func ValidateConfig() {
-	if !contains(i18nTypes, GlobalConfig.I18nType) {
-		log.Fatalln(fmt.Sprintf("Unknown localization method specified (%s). It must be one of the following: %s.", GlobalConfig.I18nType, strings.Join(i18nTypes, ", ")))
-	}
-	// Check template directory
-	switch GlobalConfig.I18nType {
-	case "domain":
-		if fi, err := os.Stat(getRootFilesPath() + GlobalConfig.PathTpls); err == nil {
-			if !fi.IsDir() {
-				log.Fatalln(fmt.Sprintf("Incorrect path for templates. The '%s%s' path is not a directory", getRootFilesPath(), GlobalConfig.PathTpls))
-			}
-		} else {
-			log.Fatalln(fmt.Sprintf("Template directory '%s%s' doesn't exist", getRootFilesPath(), GlobalConfig.PathTpls))
-		}
-	case "separate-domain":
-		if err := getDomainMap(); err != nil {
-			log.Fatal(err.Error())
+// Get regexp alternation matching any of the configured languages, e.g. "en|ru|zh"
+func languagesRegexp() string {
+	return channelsRegexp(GlobalConfig.Languages)
+}
+
+// Check whether the slice contains the string, ignoring case
+func containsFold(s []string, str string) bool {
+	for _, v := range s {
+		if strings.EqualFold(v, str) {
+			return true
 		}
 	}
 
-	// Check channels file
-	if _, err := os.Stat(GlobalConfig.PathChannelsFile); err != nil {
-		if os.IsNotExist(err) {
-			log.Fatalln(fmt.Sprintf("Channels file '%s' doesn't exist", GlobalConfig.PathChannelsFile))
+	return false
+}
+
+// Check the configuration and exit if it is invalid
+func ValidateConfig() {
+	problems := validateConfig()
+	for _, problem := range problems {
+		if problem.Warning {
+			log.Warnln(problem.String())
+		} else {
+			log.Errorln(problem.String())
 		}
-		log.Fatalln(fmt.Sprintf("Channels file '%s' access error", GlobalConfig.PathChannelsFile))
+	}
+	if hasErrors(problems) {
+		log.Fatalln("Configuration is invalid")
 	}
 }
 
@@ -135,26 +199,53 @@ func printConfiguration() {
 		log.Fatal(err)
 	}
 	log.Infoln(fmt.Sprintf("Working dir: %s", dir))
-	log.Infoln(fmt.Sprintf("Channel file used: %s", GlobalConfig.PathChannelsFile))
+	log.Infoln(fmt.Sprintf("Channel file reload interval: %s", GlobalConfig.ChannelsReloadInterval))
 	log.Infoln(fmt.Sprintf("Directory with static files: %s", getRootFilesPath()))
-	log.Infoln(fmt.Sprintf("Templates directory: %s%s", getRootFilesPath(), GlobalConfig.PathTpls))
-	log.Infoln(fmt.Sprintf("URL location for versions: %s", GlobalConfig.LocationVersions))
+	if len(GlobalConfig.PathStaticOverlays) > 0 {
+		log.Infoln(fmt.Sprintf("Static overlays (searched first): %s", strings.Join(GlobalConfig.PathStaticOverlays, ", ")))
+	}
 	log.Infoln(fmt.Sprintf("Localization method: %s", GlobalConfig.I18nType))
+	log.Infoln(fmt.Sprintf("Languages: %s (default - %s)", strings.Join(GlobalConfig.Languages, ", "), GlobalConfig.DefaultLanguage))
 	if GlobalConfig.I18nType == "separate-domain" {
 		log.Infoln(fmt.Sprintf("Domain map: %s", DomainMap))
 	}
-	log.Infoln(fmt.Sprintf("Default group: %s", GlobalConfig.DefaultGroup))
-	log.Infoln(fmt.Sprintf("Default channel: %s", GlobalConfig.DefaultChannel))
+	log.Infoln(fmt.Sprintf("Channels (from the most stable): %s", strings.Join(GlobalConfig.Channels, ", ")))
+	if len(GlobalConfig.FallbackChannels) > 0 {
+		log.Infoln(fmt.Sprintf("Fallback channels: %s", strings.Join(GlobalConfig.FallbackChannels, ", ")))
+	}
 	log.Infoln(fmt.Sprintf("Show the 'latest' channel: %v", GlobalConfig.ShowLatestChannel))
+	log.Infoln(fmt.Sprintf("Group for group-less channel URLs: %s", GlobalConfig.ChannelURLGroup))
+	if GlobalConfig.VersionCookie != "" {
+		log.Infoln(fmt.Sprintf("Version cookie: %s", GlobalConfig.VersionCookie))
+	}
+	if GlobalConfig.ProxyUpstream != "" {
+		log.Infoln(fmt.Sprintf("Proxy upstream: %s (timeout - %s)", GlobalConfig.ProxyUpstream, GlobalConfig.ProxyTimeout))
+	}
+	if GlobalConfig.Standalone {
+		log.Infoln(fmt.Sprintf("Standalone mode, versions are served from %s/%s", getRootFilesPath(), GlobalConfig.VersionsLayout))
+	}
+	if GlobalConfig.PathProductsFile != "" {
+		log.Infoln(fmt.Sprintf("Products file: %s", GlobalConfig.PathProductsFile))
+	}
 
-	if log.GetLevel() == log.TraceLevel {
-		channelFileContent, err := ioutil.ReadFile(GlobalConfig.PathChannelsFile)
+	for _, product := range Products {
+		log.Infoln(fmt.Sprintf("Product %s:", product.Name))
+		log.Infoln(fmt.Sprintf("  Channel file used: %s", product.PathChannelsFile))
+		log.Infoln(fmt.Sprintf("  Inline channels data used: %v", product.ChannelsData != ""))
+		log.Infoln(fmt.Sprintf("  Templates directory: %s%s", getRootFilesPath(), product.PathTpls))
+		log.Infoln(fmt.Sprintf("  URL location for versions: %s", product.LocationVersions))
+		log.Infoln(fmt.Sprintf("  Default group: %s", product.DefaultGroup))
+		log.Infoln(fmt.Sprintf("  Default channel: %s", product.DefaultChannel))
 
-		if err != nil {
-			log.Fatal(err)
-		}
+		if fileInfo, err := os.Stat(product.PathChannelsFile); log.GetLevel() == log.TraceLevel && err == nil && !fileInfo.IsDir() {
+			channelFileContent, err := ioutil.ReadFile(product.PathChannelsFile)
 
-		fmt.Println(string(channelFileContent))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Println(string(channelFileContent))
+		}
 	}
 }
 
@@ -169,17 +260,18 @@ func getRootRelease() (activeRelease string) {
 
 func (m *templateDataType) getChannelMenuData(r *http.Request, releases *ReleasesStatusType) (err error) {
 	err = nil
+	product := getProduct(r)
 
 	m.CurrentPageURLRelative = getDocPageURLRelative(r, false)
 	m.CurrentPageURL = getCurrentPageURL(r)
 	m.CurrentVersionURL = getVersionURL(r)
 	m.CurrentLang = getCurrentLang(r)
 
-	if isGroupChannelURL, _ := regexp.MatchString("v[0-9]+.[0-9]+-(alpha|beta|ea|stable|rock-solid)", m.CurrentVersionURL); isGroupChannelURL {
-		items := strings.Split(m.CurrentVersionURL, "-")
-		if len(items) == 2 {
-			m.CurrentGroup = items[0]
-			m.CurrentChannel = items[1]
+	// Channel aliases and mixed-case names are shown by their canonical names
+	if items := groupChannelURLRegexp.FindStringSubmatch(m.CurrentVersionURL); items != nil {
+		if channel, ok := getCanonicalChannel(releases, items[2]); ok {
+			m.CurrentGroup = items[1]
+			m.CurrentChannel = channel
 			m.CurrentVersion, _ = getVersionFromChannelAndGroup(releases, m.CurrentChannel, m.CurrentGroup)
 			m.CurrentVersionURL = VersionToURL(m.CurrentVersion)
 		}
@@ -190,7 +282,7 @@ func (m *templateDataType) getChannelMenuData(r *http.Request, releases *Release
 	m.CurrentVersion = URLToVersion(m.CurrentVersionURL)
 
 	if m.CurrentVersion == "" {
-		m.CurrentVersion = GlobalConfig.DefaultGroup
+		m.CurrentVersion = product.DefaultGroup
 		m.CurrentVersionURL = VersionToURL(m.CurrentVersion)
 	}
 
@@ -200,25 +292,32 @@ func (m *templateDataType) getChannelMenuData(r *http.Request, releases *Release
 	}
 
 	// Add the first menu item
-	m.VersionItems = append(m.VersionItems, versionMenuItems{
+	currentGroup := findGroupForVersion(releases, m.CurrentVersion)
+	m.setSupportStatus(product, releases, currentGroup)
+	currentItem := versionMenuItems{
 		Group:      m.CurrentGroup,
 		Channel:    m.CurrentChannel,
 		Version:    m.CurrentVersion,
 		VersionURL: m.CurrentVersionURL,
 		IsCurrent:  true,
-	})
+	}
+	currentItem.setSupportStatus(currentGroup)
+	currentItem.setDisplayData(currentGroup)
+	m.VersionItems = append(m.VersionItems, currentItem)
 
 	// Add other items
-	for _, group := range getGroups() {
+	for _, group := range getMenuGroups(releases) {
 		// TODO error handling
-		_ = m.getChannelsFromGroup(&ReleasesStatus, group)
+		_ = m.getChannelsFromGroup(releases, group)
 	}
+	m.MenuGroups = getMenuTree(releases, currentGroup, m.CurrentVersion, m.CurrentChannel)
 
 	return
 }
 
-func (m *templateDataType) getVersionMenuData(r *http.Request) (err error) {
+func (m *templateDataType) getVersionMenuData(r *http.Request, releases *ReleasesStatusType) (err error) {
 	err = nil
+	product := getProduct(r)
 
 	m.CurrentPageURLRelative = getDocPageURLRelative(r, false)
 	m.CurrentPageURL = getCurrentPageURL(r)
@@ -227,12 +326,12 @@ func (m *templateDataType) getVersionMenuData(r *http.Request) (err error) {
 	m.CurrentLang = getCurrentLang(r)
 
 	if m.CurrentVersion == "" {
-		re := regexp.MustCompile(fmt.Sprintf("^/[^/]%s/(.+)$", GlobalConfig.LocationVersions))
+		re := regexp.MustCompile(fmt.Sprintf("^/[^/]%s/(.+)$", product.LocationVersions))
 		res := re.FindStringSubmatch(m.CurrentPageURL)
 		if res == nil {
 			m.MenuDocumentationLink = ""
 		} else {
-			m.CurrentVersion = GlobalConfig.DefaultGroup
+			m.CurrentVersion = product.DefaultGroup
 			m.CurrentVersionURL = VersionToURL(m.CurrentVersion)
 		}
 	}
@@ -242,33 +341,43 @@ func (m *templateDataType) getVersionMenuData(r *http.Request) (err error) {
 	if res != nil {
 		if res[2] != "" {
 			// Version is not a group (MAJ.MIN), but the patch version
-			m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", GlobalConfig.LocationVersions, VersionToURL(res[0]))
+			m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", product.LocationVersions, VersionToURL(res[0]))
 			m.AbsoluteVersion = m.CurrentVersion
 		} else {
-			m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", GlobalConfig.LocationVersions, VersionToURL(m.CurrentVersion))
-			m.AbsoluteVersion, err = getVersionFromGroup(&ReleasesStatus, res[1])
+			m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", product.LocationVersions, VersionToURL(m.CurrentVersion))
+			m.AbsoluteVersion, err = getVersionFromGroup(product, releases, res[1])
 			if err != nil {
 				log.Debugln(fmt.Sprintf("getVersionMenuData: error determine absolute version for %s (got %s)", m.CurrentVersion, m.AbsoluteVersion))
 			}
 		}
 	} else if GlobalConfig.ShowLatestChannel && m.CurrentVersion == "latest" {
-		m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", GlobalConfig.LocationVersions, m.CurrentVersion)
+		m.MenuDocumentationLink = fmt.Sprintf("%s/%s/", product.LocationVersions, m.CurrentVersion)
 		m.AbsoluteVersion = m.CurrentVersion
 	}
 
 	// Add the first menu item
-	m.VersionItems = append(m.VersionItems, versionMenuItems{
+	currentGroup := findGroupForVersion(releases, m.CurrentVersion)
+	m.setSupportStatus(product, releases, currentGroup)
+	currentItem := versionMenuItems{
 		Group:      m.CurrentGroup,
 		Channel:    m.CurrentChannel,
 		Version:    m.CurrentVersion,
 		VersionURL: m.CurrentVersionURL,
 		IsCurrent:  true,
-	})
+	}
+	currentItem.setSupportStatus(currentGroup)
+	currentItem.setDisplayData(currentGroup)
+	m.VersionItems = append(m.VersionItems, currentItem)
 
 	// Add other items
-	for _, group := range getGroups() {
+	for _, group := range getMenuGroups(releases) {
 		// TODO error handling
-		_ = m.getChannelsFromGroup(&ReleasesStatus, group)
+		_ = m.getChannelsFromGroup(releases, group)
+	}
+	if m.AbsoluteVersion != "" {
+		m.MenuGroups = getMenuTree(releases, currentGroup, m.AbsoluteVersion, "")
+	} else {
+		m.MenuGroups = getMenuTree(releases, currentGroup, m.CurrentVersion, "")
 	}
 
 	// Add the "latest" menu item
@@ -285,8 +394,9 @@ func (m *templateDataType) getVersionMenuData(r *http.Request) (err error) {
 	return
 }
 
-func (m *templateDataType) getGroupMenuData(r *http.Request) (err error) {
+func (m *templateDataType) getGroupMenuData(r *http.Request, releases *ReleasesStatusType) (err error) {
 	err = nil
+	product := getProduct(r)
 
 	m.CurrentPageURLRelative = getDocPageURLRelative(r, false)
 	m.CurrentPageURL = getCurrentPageURL(r)
@@ -295,7 +405,7 @@ func (m *templateDataType) getGroupMenuData(r *http.Request) (err error) {
 	m.CurrentLang = getCurrentLang(r)
 
 	if m.CurrentVersion == "" {
-		m.CurrentVersion = GlobalConfig.DefaultGroup
+		m.CurrentVersion = product.DefaultGroup
 		m.CurrentVersionURL = VersionToURL(m.CurrentVersion)
 	}
 
@@ -321,16 +431,20 @@ func (m *templateDataType) getGroupMenuData(r *http.Request) (err error) {
 	}
 
 	// Add other items
-	for _, group := range getGroups() {
+	for _, group := range getMenuGroups(releases) {
 		// TODO error handling
-		m.VersionItems = append(m.VersionItems, versionMenuItems{
+		item := versionMenuItems{
 			Group:      group,
 			Channel:    "",
 			Version:    "",
 			VersionURL: "",
 			IsCurrent:  false,
-		})
+		}
+		item.setSupportStatus(getReleaseGroup(releases, group))
+		item.setDisplayData(getReleaseGroup(releases, group))
+		m.VersionItems = append(m.VersionItems, item)
 	}
+	m.MenuGroups = getMenuTree(releases, findGroupForVersion(releases, m.CurrentVersion), m.CurrentVersion, "")
 
 	return
 }
@@ -340,16 +454,20 @@ func (m *templateDataType) getGroupMenuData(r *http.Request) (err error) {
 func (m *templateDataType) getChannelsFromGroup(releases *ReleasesStatusType, group string) (err error) {
 	for _, item := range releases.Groups {
 		if item.Name == group {
-			for _, channel := range channelsListReverseStability {
+			for _, channel := range getChannelsListReverseStability(releases) {
 				for _, channelItem := range item.Channels {
 					if channelItem.Name == channel {
-						m.VersionItems = append(m.VersionItems, versionMenuItems{
+						menuItem := versionMenuItems{
 							Group:      group,
 							Channel:    channelItem.Name,
 							Version:    channelItem.Version,
 							VersionURL: VersionToURL(channelItem.Version),
 							IsCurrent:  false,
-						})
+							History:    channelItem.SortedHistory(),
+						}
+						menuItem.setSupportStatus(&item)
+						menuItem.setDisplayData(&item)
+						m.VersionItems = append(m.VersionItems, menuItem)
 					}
 				}
 			}
@@ -367,8 +485,8 @@ func getChannelAndGroupFromVersion(releases *ReleasesStatusType, version string)
 		return "", res[1]
 	}
 
-	for _, group := range getGroups() {
-		for _, channel := range channelsListReverseStability {
+	for _, group := range getGroups(releases) {
+		for _, channel := range getChannelsListReverseStability(releases) {
 			for _, releaseItem := range releases.Groups {
 				if releaseItem.Name == group {
 					for _, channelItem := range releaseItem.Channels {
@@ -399,68 +517,89 @@ func getVersionFromChannelAndGroup(releases *ReleasesStatusType, channel, group
 	return "", fmt.Errorf("no matching version for group %s, channel %s", group, channel)
 }
 
+// Get the version of the channel for the group-less channel URL, e.g. v1.3.2 for /documentation/stable/.
+// The channel is looked up in the newest visible group having the channel, or in the default group (VROUTER_CHANNEL_URL_GROUP).
+func getVersionFromChannel(product *ProductType, releases *ReleasesStatusType, channel string) (version string, err error) {
+	groups := getVisibleGroups(releases)
+	if GlobalConfig.ChannelURLGroup == "default" {
+		groups = []string{product.DefaultGroup}
+	}
+	for _, group := range groups {
+		if version, err = getVersionFromChannelAndGroup(releases, channel, group); err == nil {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("no matching version for channel %s", channel)
+}
+
 // Gev version from specified group
 // E.g. get v1.2.3+fix6 from v1.2
-func getVersionFromGroup(releases *ReleasesStatusType, group string) (version string, err error) {
-	releaseVersions := make(map[string]string)
-
-	if GlobalConfig.DefaultChannel == "latest" {
-		return "latest", nil
+func getVersionFromGroup(product *ProductType, releases *ReleasesStatusType, group string) (version string, err error) {
+	resolution, err := resolveGroupVersion(product, releases, group)
+	if err != nil {
+		return "", err
 	}
+	log.Debugln(fmt.Sprintf("Chose %s for group %s: %s", resolution.Version, group, resolution.Reason))
+	return resolution.Version, nil
+}
 
-	if len(releases.Groups) > 0 {
-		for _, ReleaseGroup := range releases.Groups {
-			if ReleaseGroup.Name == group {
-				for _, channel := range ReleaseGroup.Channels {
-					releaseVersions[channel.Name] = channel.Version
-				}
-
-				if _, ok := releaseVersions["stable"]; ok && GlobalConfig.DefaultChannel == "stable" {
-					return releaseVersions["stable"], nil
-				} else if _, ok := releaseVersions["ea"]; ok && (GlobalConfig.DefaultChannel == "stable" || GlobalConfig.DefaultChannel == "ea") {
-					return releaseVersions["ea"], nil
-				} else if _, ok := releaseVersions["beta"]; ok && (GlobalConfig.DefaultChannel == "stable" || GlobalConfig.DefaultChannel == "ea" || GlobalConfig.DefaultChannel == "beta") {
-					return releaseVersions["beta"], nil
-				} else if _, ok := releaseVersions["alpha"]; ok && (GlobalConfig.DefaultChannel == "stable" || GlobalConfig.DefaultChannel == "ea" || GlobalConfig.DefaultChannel == "beta" || GlobalConfig.DefaultChannel == "alpha") {
-					return releaseVersions["alpha"], nil
-				}
-			}
-		}
+// Get the version the root documentation URL leads to, i.e. the version of the default group
+func getRootReleaseVersion(product *ProductType, releases *ReleasesStatusType) string {
+	if version, err := getVersionFromGroup(product, releases, product.DefaultGroup); err == nil {
+		return version
 	}
+	return "unknown"
+}
 
-	log.Errorln(fmt.Sprintf("can't get version for group %s, chose %s for alpha channel ", group, releaseVersions["alpha"]))
-	if len(releaseVersions["alpha"]) > 0 {
-		return releaseVersions["alpha"], nil
-	} else {
-		return "", fmt.Errorf("can't get version for group %s", group)
+// Get channel names ordered from the most stable to the least stable one.
+// Channels declared in the channels file take precedence over the VROUTER_CHANNELS value.
+func getChannelsListReverseStability(releases *ReleasesStatusType) []string {
+	if releases != nil && len(releases.Channels) > 0 {
+		return releases.Channels
 	}
+	return GlobalConfig.Channels
+}
 
+// Get regexp alternation matching any of the specified channels, e.g. "stable|ea|beta"
+func channelsRegexp(channels []string) string {
+	var items []string
+	for _, channel := range channels {
+		items = append(items, regexp.QuoteMeta(channel))
+	}
+	return strings.Join(items, "|")
 }
 
-func getRootReleaseVersion() string {
-	_ = updateReleasesStatus()
+// Get channels allowed in URLs, including the 'latest' channel if it is enabled
+func getURLChannels(releases *ReleasesStatusType) []string {
+	channels := getChannelsListReverseStability(releases)
+	if GlobalConfig.ShowLatestChannel {
+		channels = append([]string{"latest"}, channels...)
+	}
+	return channels
+}
 
-	if len(ReleasesStatus.Groups) > 0 {
-		for _, ReleaseGroup := range ReleasesStatus.Groups {
-			if ReleaseGroup.Name == GlobalConfig.DefaultGroup {
-				releaseVersions := make(map[string]string)
-				for _, channel := range ReleaseGroup.Channels {
-					releaseVersions[channel.Name] = channel.Version
-				}
+// Get regexp alternation matching any channel allowed in URLs, including the 'latest' channel if it is enabled
+func getChannelsURLRegexp(releases *ReleasesStatusType) string {
+	return channelsRegexp(getURLChannels(releases))
+}
 
-				if _, ok := releaseVersions["stable"]; ok {
-					return releaseVersions["stable"]
-				} else if _, ok := releaseVersions["ea"]; ok {
-					return releaseVersions["ea"]
-				} else if _, ok := releaseVersions["beta"]; ok {
-					return releaseVersions["beta"]
-				} else if _, ok := releaseVersions["alpha"]; ok {
-					return releaseVersions["alpha"]
-				}
+// Get the canonical channel name by the channel name or alias from the URL, ignoring case.
+// E.g. "ea" for "EA" or for "early-access", if the alias is defined in the channels file.
+func getCanonicalChannel(releases *ReleasesStatusType, name string) (string, bool) {
+	channels := getURLChannels(releases)
+	for _, channel := range channels {
+		if strings.EqualFold(channel, name) {
+			return channel, true
+		}
+	}
+	if releases != nil {
+		for alias, channel := range releases.Aliases {
+			if strings.EqualFold(alias, name) && contains(channels, channel) {
+				return channel, true
 			}
 		}
 	}
-	return "unknown"
+	return "", false
 }
 
 // Get the full page URL menu requested for
@@ -481,7 +620,8 @@ func getCurrentPageURL(r *http.Request) (result string) {
 // Get the full page URL menu requested for
 // E.g /documentation/v1.2.3/reference/build_process.html
 func getCurrentLang(r *http.Request) (result string) {
-	result = "en"
+	result = GlobalConfig.DefaultLanguage
+	product := getProduct(r)
 
 	switch GlobalConfig.I18nType {
 	case "separate-domain":
@@ -499,8 +639,7 @@ func getCurrentLang(r *http.Request) (result string) {
 			return
 		}
 
-		re := regexp.MustCompile(fmt.Sprintf("^/(ru|en)%s/.+$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(originalURI.Path)
+		res := product.regexps.langPage.FindStringSubmatch(originalURI.Path)
 		if res != nil {
 			result = res[1]
 		}
@@ -518,6 +657,7 @@ func getDocPageURLRelative(r *http.Request, useURI bool) (result string) {
 		originalURI *url.URL
 		err         error
 	)
+	product := getProduct(r)
 
 	if useURI {
 		originalURI, err = url.Parse(r.RequestURI)
@@ -535,8 +675,7 @@ func getDocPageURLRelative(r *http.Request, useURI bool) (result string) {
 	URLtoParse = originalURI.Path
 
 	if GlobalConfig.I18nType == "location" {
-		re := regexp.MustCompile(fmt.Sprintf("^/(ru|en)(%s/[^/]+)?/(.*)$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(URLtoParse)
+		res := product.regexps.langPageRelative.FindStringSubmatch(URLtoParse)
 		if res != nil {
 			if len(res[2]) > 0 {
 				result = res[3]
@@ -545,8 +684,7 @@ func getDocPageURLRelative(r *http.Request, useURI bool) (result string) {
 			}
 		}
 	} else {
-		re := regexp.MustCompile(fmt.Sprintf("^%s/[^/]+/(.*)$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(URLtoParse)
+		res := product.regexps.pageRelative.FindStringSubmatch(URLtoParse)
 		if res != nil {
 			result = res[1]
 		}
@@ -558,7 +696,7 @@ func getDocPageURLRelative(r *http.Request, useURI bool) (result string) {
 // Get version URL page belongs to if request came from concrete documentation version, otherwise empty.
 // E.g for the /documentation/v1.2.3-plus-fix5/reference/build_process.html return "v1.2.3-plus-fix5".
 func getVersionURL(r *http.Request) (result string) {
-	var re *regexp.Regexp
+	product := getProduct(r)
 
 	URLtoParse := ""
 	originalURI, err := url.Parse(r.Header.Get("x-original-uri"))
@@ -578,14 +716,12 @@ func getVersionURL(r *http.Request) (result string) {
 	}
 
 	if GlobalConfig.I18nType == "location" {
-		re = regexp.MustCompile(fmt.Sprintf("^/(ru|en)%s/([^/]+)/?.*$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(URLtoParse)
+		res := product.regexps.langVersionURL.FindStringSubmatch(URLtoParse)
 		if res != nil {
 			result = res[2]
 		}
 	} else {
-		re = regexp.MustCompile(fmt.Sprintf("^%s/([^/]+)/?.*$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(URLtoParse)
+		res := product.regexps.versionURL.FindStringSubmatch(URLtoParse)
 		if res != nil {
 			result = res[1]
 		}
@@ -660,23 +796,45 @@ func validateURL(url string) (err error) {
 }
 
 // Get update channel groups in a descending order.
-func getGroups() (groups []string) {
-	for _, item := range ReleasesStatus.Groups {
+func getGroups(releases *ReleasesStatusType) (groups []string) {
+	for _, item := range releases.Groups {
 		groups = append(groups, item.Name)
 	}
-	// TODO compare groups function
-	sort.Slice(groups, func(i, j int) bool {
-		var _i, _j float64
-		var err error
-		if _i, err = strconv.ParseFloat(groups[i], 32); err != nil {
-			_i = 0
+	sortVersionsDescending(groups)
+	return
+}
+
+// Get names of the groups shown in menus, from the newest to the oldest one
+func getVisibleGroups(releases *ReleasesStatusType) (groups []string) {
+	for _, group := range getGroups(releases) {
+		if item := getReleaseGroup(releases, group); item != nil && !item.Hidden {
+			groups = append(groups, group)
 		}
-		if _j, err = strconv.ParseFloat(groups[j], 32); err != nil {
-			_j = 0
+	}
+	return
+}
+
+// Get names of the groups shown in menus in the menu order.
+// Groups with the weight go first, ordered by the weight, then other groups from the newest to the oldest one.
+func getMenuGroups(releases *ReleasesStatusType) []string {
+	groups := getVisibleGroups(releases)
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := getReleaseGroup(releases, groups[i]).Weight, getReleaseGroup(releases, groups[j]).Weight
+		if a == 0 || b == 0 {
+			return a != 0 && b == 0
 		}
-		return _i > _j
+		return a < b
 	})
-	return
+	return groups
+}
+
+// Set display fields of the menu item for the group
+func (i *versionMenuItems) setDisplayData(group *ReleaseType) {
+	if group == nil {
+		return
+	}
+	i.Title = getGroupTitle(group)
+	i.Badges = group.Badges
 }
 
 func getRootFilesPath() string {
@@ -684,35 +842,11 @@ func getRootFilesPath() string {
 }
 
 func unmarshalJSON(data []byte, config interface{}) error {
-	err := json.Unmarshal(data, config)
-	if err != nil {
-		log.Errorf("Can't unmarshall %s (%e)", GlobalConfig.PathChannelsFile, err)
-		return err
-	}
-	return nil
+	return json.Unmarshal(data, config)
 }
 
 func unmarshalYAML(data []byte, config interface{}) error {
-	err := yaml.Unmarshal(data, config)
-	if err != nil {
-		log.Errorf("Can't unmarshall %s (%e)", GlobalConfig.PathChannelsFile, err)
-		return err
-	}
-	return nil
-}
-
-func updateReleasesStatus() error {
-	data, err := ioutil.ReadFile(GlobalConfig.PathChannelsFile)
-	if err != nil {
-		log.Errorf("Can't open %s (%e)", GlobalConfig.PathChannelsFile, err)
-		return err
-	}
-	if strings.HasSuffix(GlobalConfig.PathChannelsFile, ".json") {
-		return unmarshalJSON(data, &ReleasesStatus)
-	} else if strings.HasSuffix(GlobalConfig.PathChannelsFile, ".yaml") || strings.HasSuffix(GlobalConfig.PathChannelsFile, ".yml") {
-		return unmarshalYAML(data, &ReleasesStatus)
-	}
-	return fmt.Errorf("failed to decode channels file %s", GlobalConfig.PathChannelsFile)
+	return yaml.Unmarshal(data, config)
 }
 
 func getDomainMap() error {
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetVersionFromGroupCustomChannels(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+
+	releases := ReleasesStatusType{
+		Channels: []string{"lts", "preview", "nightly"},
+		Groups: []ReleaseType{
+			{Name: "v1", Channels: []ChannelType{{Name: "nightly", Version: "v1.3.0"}, {Name: "preview", Version: "v1.2.0"}}},
+		},
+	}
+
+	version, err := getVersionFromGroup(&ProductType{DefaultChannel: "lts"}, &releases, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.2.0" {
+		t.Errorf("Wrong version for the v1 group, expected v1.2.0, got %s", version)
+	}
+
+	menu := templateDataType{}
+	_ = menu.getChannelsFromGroup(&releases, "v1")
+	if len(menu.VersionItems) != 2 || menu.VersionItems[0].Channel != "preview" || menu.VersionItems[1].Channel != "nightly" {
+		t.Errorf("Wrong channel order in the menu: %+v", menu.VersionItems)
+	}
+}
+
+func TestChannelAliases(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.I18nType = "location"
+
+	releases := &ReleasesStatusType{
+		Aliases: map[string]string{"early-access": "ea", "rocksolid": "rock-solid", "broken": "unknown"},
+		Groups: []ReleaseType{
+			{Name: "v1.2", Channels: []ChannelType{{Name: "ea", Version: "v1.2.3"}, {Name: "rock-solid", Version: "v1.2.1"}}},
+		},
+	}
+
+	for name, expected := range map[string]string{
+		"ea":           "ea",
+		"Stable":       "stable",
+		"Early-Access": "ea",
+		"rocksolid":    "rock-solid",
+		"broken":       "",
+		"nightly":      "",
+	} {
+		if channel, _ := getCanonicalChannel(releases, name); channel != expected {
+			t.Errorf("Wrong canonical channel for %s, expected %q, got %q", name, expected, channel)
+		}
+	}
+
+	product := newTestProduct(t, releases)
+
+	router := newRouter()
+	for path, expected := range map[string]string{
+		"/en/documentation/v1.2-early-access/cli/?q=1": "/en/documentation/v1.2-ea/cli/?q=1",
+		"/en/documentation/v1.2-RockSolid/":            "/en/documentation/v1.2-rock-solid/",
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusMovedPermanently || recorder.Header().Get("Location") != expected {
+			t.Errorf("%s: expected redirect to %s, got %d %s", path, expected, recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+
+	menu := templateDataType{}
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/en/documentation/v1.2-early-access/")
+	_ = menu.getChannelMenuData(withProduct(r, product), releases)
+	if menu.CurrentChannel != "ea" || menu.CurrentVersion != "v1.2.3" {
+		t.Errorf("Wrong current channel in the menu, expected ea (v1.2.3), got %s (%s)", menu.CurrentChannel, menu.CurrentVersion)
+	}
+}
+
+func TestGrouplessChannelURL(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.UrlValidation = false
+
+	releases := &ReleasesStatusType{
+		Aliases: map[string]string{"early-access": "ea"},
+		Groups: []ReleaseType{
+			{Name: "v1.1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.1.6"}}},
+			{Name: "v1.10", Channels: []ChannelType{{Name: "ea", Version: "v1.10.1"}}},
+			{Name: "v1.2", Channels: []ChannelType{{Name: "stable", Version: "v1.2.3"}}},
+		},
+	}
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v1.1"
+
+	tests := []struct {
+		group, path, expected string
+	}{
+		{"newest", "/en/documentation/stable/reference/cli.html?q=1", "/en/documentation/v1.2.3/reference/cli.html?q=1"},
+		{"newest", "/en/documentation/ea", "/en/documentation/v1.10.1/"},
+		{"newest", "/en/documentation/Early-Access/", "/en/documentation/ea/"},
+		{"default", "/ru/documentation/stable/", "/ru/documentation/v1.1.5/"},
+	}
+	router := newRouter()
+	for _, test := range tests {
+		GlobalConfig.ChannelURLGroup = test.group
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+		if location := recorder.Header().Get("Location"); location != test.expected {
+			t.Errorf("%s (%s group): expected redirect to %s, got %d %s", test.path, test.group, test.expected, recorder.Code, location)
+		}
+	}
+
+	// Pages which names don't match channels are not redirected to a version
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/en/documentation/stablex/", nil))
+	if location := recorder.Header().Get("Location"); location == "/en/documentation/v1.2.3/" {
+		t.Errorf("Unexpected channel redirect for /en/documentation/stablex/")
+	}
+}
+
+func TestVersionRangeURL(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.UrlValidation = false
+
+	newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1.2", Channels: []ChannelType{{Name: "stable", Version: "v1.2.3"}, {Name: "ea", Version: "v1.2.4+fix1"}}},
+	}})
+
+	router := newRouter()
+	for path, expected := range map[string]string{
+		"/en/documentation/v1.2.x/reference/cli.html": "/en/documentation/v1.2.4-plus-fix1/reference/cli.html",
+		"/ru/documentation/~1.2/":                     "/ru/documentation/v1.2.4-plus-fix1/",
+	} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if location := recorder.Header().Get("Location"); recorder.Code != http.StatusFound || location != expected {
+			t.Errorf("%s: expected redirect to %s, got %d %s", path, expected, recorder.Code, location)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/en/documentation/v2.x/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a range without versions, got %d", recorder.Code)
+	}
+}
+
+func TestHiddenGroups(t *testing.T) {
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.ChannelURLGroup = "newest"
+
+	releases := &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1.1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}}},
+		{Name: "v2.0", Channels: []ChannelType{{Name: "stable", Version: "v2.0.1"}}, Hidden: true},
+	}}
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v1.1"
+
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/documentation/v1.1.5/")
+	menu := templateDataType{}
+	_ = menu.getVersionMenuData(withProduct(r, product), releases)
+	for _, item := range menu.VersionItems {
+		if item.Group == "v2.0" {
+			t.Errorf("Hidden group is shown in the menu: %+v", item)
+		}
+	}
+
+	if version, err := getVersionFromGroup(product, releases, "v2.0"); err != nil || version != "v2.0.1" {
+		t.Errorf("Hidden group must be routable, got %q (%v)", version, err)
+	}
+	if version, _ := getVersionFromChannel(product, releases, "stable"); version != "v1.1.5" {
+		t.Errorf("Hidden group must not be used for group-less channel URLs, got %s", version)
+	}
+}
+
+func TestMenuDisplayData(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+
+	releases := &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1.1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}}},
+		{Name: "v1.2", Channels: []ChannelType{{Name: "stable", Version: "v1.2.3"}}, Title: "1.2 LTS", Badges: []string{"recommended"}, Weight: 1},
+		{Name: "v1.3", Channels: []ChannelType{{Name: "ea", Version: "v1.3.1"}}, Badges: []string{"new"}},
+		{Name: "v1.0", Channels: []ChannelType{{Name: "stable", Version: "v1.0.9"}}, Weight: 2},
+	}}
+
+	if groups := getMenuGroups(releases); strings.Join(groups, ",") != "v1.2,v1.0,v1.3,v1.1" {
+		t.Errorf("Wrong menu order: %v", groups)
+	}
+
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v1.2"
+	r := httptest.NewRequest("GET", "/includes/group-menu.html", nil)
+	r.Header.Set("x-original-uri", "/documentation/v1.2/")
+	menu := templateDataType{}
+	_ = menu.getGroupMenuData(withProduct(r, product), releases)
+	titles := make(map[string]versionMenuItems)
+	for _, item := range menu.VersionItems[1:] {
+		titles[item.Group] = item
+	}
+	if item := titles["v1.2"]; item.Title != "1.2 LTS" || len(item.Badges) != 1 || item.Badges[0] != "recommended" {
+		t.Errorf("Wrong display data of the v1.2 group: %+v", item)
+	}
+	if item := titles["v1.1"]; item.Title != "1.1" || len(item.Badges) != 0 {
+		t.Errorf("Wrong display data of the v1.1 group: %+v", item)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	GlobalConfig.Languages = []string{"en", "ru", "zh", "de"}
+	GlobalConfig.DefaultLanguage = "en"
+
+	for host, expected := range map[string]string{
+		"zh.example.com":      "zh",
+		"www.de.example.com":  "de",
+		"example.com:8080":    "en",
+		"fr.example.com":      "en",
+		"ru.example.com:8080": "ru",
+	} {
+		if lang := getLanguageFromDomain(host); lang != expected {
+			t.Errorf("Wrong language for %s, expected %s, got %s", host, expected, lang)
+		}
+	}
+
+	DomainMap = map[string]string{"zh": "example.cn", "en": "example.com"}
+	defer func() { DomainMap = nil }()
+	if lang := getLanguageFromDomainMap("www.example.cn"); lang != "zh" {
+		t.Errorf("Wrong language for www.example.cn, expected zh, got %s", lang)
+	}
+	if lang := getLanguageFromDomainMap("example.org"); lang != "en" {
+		t.Errorf("Wrong language for example.org, expected the default language, got %s", lang)
+	}
+
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.UrlValidation = false
+	product := newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.0"}}}}})
+
+	recorder := httptest.NewRecorder()
+	newRouter().ServeHTTP(recorder, httptest.NewRequest("GET", "/zh/documentation/v1-stable/cli/", nil))
+	if location := recorder.Header().Get("Location"); location != "/zh/documentation/v1.1.0/cli/" {
+		t.Errorf("Wrong redirect for the zh language: %d %s", recorder.Code, location)
+	}
+
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/de/documentation/v1.1.0/cli/")
+	if lang := getCurrentLang(withProduct(r, product)); lang != "de" {
+		t.Errorf("Wrong current language, expected de, got %s", lang)
+	}
+	if page := getDocPageURLRelative(withProduct(r, product), false); page != "cli/" {
+		t.Errorf("Wrong relative page URL, expected cli/, got %s", page)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// How long the language and version cookies are kept
+const cookieMaxAge = 365 * 24 * time.Hour
+
+// Query parameter resetting the remembered version, e.g. /documentation/?reset-version
+const versionResetQueryParam = "reset-version"
+
+// Get the name of the cookie remembering the version of the product, or an empty string if the cookie is disabled.
+// Products of the products file have their own cookies.
+func getVersionCookieName(product *ProductType) string {
+	if GlobalConfig.VersionCookie == "" || GlobalConfig.PathProductsFile == "" {
+		return GlobalConfig.VersionCookie
+	}
+	return fmt.Sprintf("%s-%s", GlobalConfig.VersionCookie, product.Name)
+}
+
+// Get the group and the channel remembered in the version cookie, e.g. "v1.2" and "ea" for the "v1.2-ea" value.
+// The channel is empty if the reader chose the group only. Unknown groups and channels are ignored.
+func getRememberedVersion(r *http.Request, product *ProductType, releases *ReleasesStatusType) (group, channel string) {
+	name := getVersionCookieName(product)
+	if name == "" {
+		return
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return
+	}
+
+	group = cookie.Value
+	if items := groupChannelURLRegexp.FindStringSubmatch(cookie.Value); items != nil {
+		group, channel = items[1], items[2]
+	}
+	if getReleaseGroup(releases, group) == nil {
+		return "", ""
+	}
+	if channel != "" {
+		if _, err := getVersionFromChannelAndGroup(releases, channel, group); err != nil {
+			channel = ""
+		}
+	}
+	return
+}
+
+// Check whether the request has the version cookie
+func hasVersionCookie(r *http.Request, product *ProductType) bool {
+	name := getVersionCookieName(product)
+	if name == "" {
+		return false
+	}
+	_, err := r.Cookie(name)
+	return err == nil
+}
+
+// Remember the group and the channel (if not empty) chosen by the reader
+func rememberVersion(w http.ResponseWriter, product *ProductType, group, channel string) {
+	name := getVersionCookieName(product)
+	if name == "" {
+		return
+	}
+	value := group
+	if channel != "" {
+		value = fmt.Sprintf("%s-%s", group, channel)
+	}
+	http.SetCookie(w, &http.Cookie{Name: name, Value: value, Path: "/", MaxAge: int(cookieMaxAge.Seconds())})
+}
+
+// Remove the version cookie
+func forgetVersion(w http.ResponseWriter, product *ProductType) {
+	if name := getVersionCookieName(product); name != "" {
+		http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1})
+	}
+}
+
+// Set the remembered version fields of the template data
+func (m *templateDataType) setRememberedVersion(r *http.Request, product *ProductType, releases *ReleasesStatusType) {
+	if getVersionCookieName(product) == "" {
+		return
+	}
+	m.RememberedGroup, m.RememberedChannel = getRememberedVersion(r, product, releases)
+
+	var langPrefix string
+	if GlobalConfig.I18nType == "location" {
+		langPrefix = fmt.Sprintf("/%s", getCurrentLang(r))
+	}
+	m.VersionResetURL = fmt.Sprintf("%s%s/?%s", langPrefix, product.LocationVersions, versionResetQueryParam)
+}
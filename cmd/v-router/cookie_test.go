@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionCookie(t *testing.T) {
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.UrlValidation = false
+	GlobalConfig.VersionCookie = "docs-version"
+
+	releases := &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.1.6"}}},
+		{Name: "v2", Channels: []ChannelType{{Name: "stable", Version: "v2.0.3"}, {Name: "ea", Version: "v2.0.4"}}},
+	}}
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v2"
+	router := newRouter()
+
+	serve := func(path, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "docs-version", Value: cookie})
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+	getCookie := func(recorder *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == "docs-version" {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	// Visiting a group channel remembers it
+	if cookie := getCookie(serve("/documentation/v1-ea/cli/", "")); cookie == nil || cookie.Value != "v1-ea" {
+		t.Errorf("Group channel is not remembered: %v", cookie)
+	}
+
+	// Versionless requests use the remembered version
+	recorder := serve("/documentation/", "v1-ea")
+	if location := recorder.Header().Get("Location"); recorder.Code != http.StatusFound || location != "/documentation/v1-ea/" {
+		t.Errorf("Remembered version is not used: %d %s", recorder.Code, location)
+	}
+	if location := serve("/documentation/", "v0.9").Header().Get("Location"); location != "/documentation/v2/" {
+		t.Errorf("Unknown remembered group must be ignored, got redirect to %s", location)
+	}
+
+	// The remembered channel is used for groups
+	recorder = serve("/documentation/v2/cli/", "v1-ea")
+	if redirect := recorder.Header().Get("X-Accel-Redirect"); redirect != "/documentation/v2.0.4/cli/" {
+		t.Errorf("Remembered channel is not used for the group, got %s", redirect)
+	}
+	if cookie := getCookie(recorder); cookie == nil || cookie.Value != "v2-ea" {
+		t.Errorf("Group is not remembered: %v", cookie)
+	}
+
+	// Reset
+	recorder = serve("/documentation/?reset-version", "v1-ea")
+	if location := recorder.Header().Get("Location"); location != "/documentation/v2/" {
+		t.Errorf("Remembered version is not reset, got redirect to %s", location)
+	}
+	if cookie := getCookie(recorder); cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("Version cookie is not removed: %v", cookie)
+	}
+	// Following the reset redirect without the cookie doesn't remember the default group again
+	recorder = serve(recorder.Header().Get("Location"), "")
+	if redirect := recorder.Header().Get("X-Accel-Redirect"); redirect != "/documentation/v2.0.3/" {
+		t.Errorf("Default group is not served after the reset, got %s", redirect)
+	}
+	if cookie := getCookie(recorder); cookie != nil {
+		t.Errorf("Version cookie is set again after the reset: %v", cookie)
+	}
+	if location := serve("/documentation/", "").Header().Get("Location"); location != "/documentation/v2/" {
+		t.Errorf("Reset version is remembered again, got redirect to %s", location)
+	}
+	if cookie := getCookie(serve("/documentation/v2/", "v1")); cookie == nil || cookie.Value != "v2" {
+		t.Errorf("Switching to the default group is not remembered: %v", cookie)
+	}
+
+	// Template data
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/documentation/v1.1.6/")
+	r.AddCookie(&http.Cookie{Name: "docs-version", Value: "v1-ea"})
+	data := templateDataType{}
+	data.setRememberedVersion(withProduct(r, product), product, releases)
+	if data.RememberedGroup != "v1" || data.RememberedChannel != "ea" || data.VersionResetURL != "/documentation/?reset-version" {
+		t.Errorf("Wrong remembered version in template data: %+v", data)
+	}
+}
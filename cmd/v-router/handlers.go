@@ -8,11 +8,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"net/http"
-	"os"
 	"path"
-	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -24,37 +22,97 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
-	err := updateReleasesStatus()
-	if err != nil {
-		msg = append(msg, err.Error())
+	product := getDefaultProduct()
+	if name := r.URL.Query().Get("product"); name != "" {
+		if product = getProductByName(name); product == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(APIStatusResponseType{Status: "error", Product: name, Msg: fmt.Sprintf("Unknown product %s.", name)})
+			return
+		}
+	}
+
+	releases := product.getReleasesStatus()
+	rootVersion := "unknown"
+	rootResolution, err := resolveGroupVersion(product, releases, product.DefaultGroup)
+	if err == nil {
+		rootVersion = rootResolution.Version
+	}
+
+	response := APIStatusResponseType{
+		Product:        product.Name,
+		RootVersion:    rootVersion,
+		RootVersionURL: VersionToURL(rootVersion),
+		RootReason:     rootResolution.Reason,
+		NewestVersion:  getNewestVersion(releases),
+		Releases:       releases.Groups,
+	}
+	for _, group := range getGroups(releases) {
+		resolution, _ := resolveGroupVersion(product, releases, group)
+		response.GroupVersions = append(response.GroupVersions, resolution)
+	}
+
+	loadState := product.getReleasesLoadState()
+	if !loadState.LoadTime.IsZero() {
+		response.LoadTime = &loadState.LoadTime
+	}
+	if loadState.LastError != "" {
+		msg = append(msg, loadState.LastError)
 		status = "error"
+		response.LastError = loadState.LastError
+		response.LastErrorTime = &loadState.LastErrorTime
+	}
+	response.Sources = loadState.Sources
+
+	if date := r.URL.Query().Get("date"); date != "" {
+		if t, err := parseHistoryTime(date); err == nil {
+			response.VersionsAt = getVersionsAt(releases, t)
+		} else {
+			msg = append(msg, fmt.Sprintf("Can't parse the date %s, use the YYYY-MM-DD or RFC 3339 format.", date))
+			status = "error"
+		}
 	}
 
-	_ = json.NewEncoder(w).Encode(
-		APIStatusResponseType{
-			Status:         status,
-			Msg:            strings.Join(msg, " "),
-			RootVersion:    getRootReleaseVersion(),
-			RootVersionURL: VersionToURL(getRootReleaseVersion()),
-			Releases:       ReleasesStatus.Groups,
-		})
+	response.Status = status
+	response.Msg = strings.Join(msg, " ")
+	_ = json.NewEncoder(w).Encode(response)
 }
 
 // X-Redirect to the stablest documentation version for specific group
 func groupHandler(w http.ResponseWriter, r *http.Request) {
 	var langPrefix string
 
-	_ = updateReleasesStatus()
 	log.Debugln("Use handler - groupHandler")
+	product := getProduct(r)
 
 	vars := mux.Vars(r)
 	if len(vars["lang"]) > 0 && GlobalConfig.I18nType == "location" {
 		langPrefix = fmt.Sprintf("/%s", vars["lang"])
 	}
 
-	if version, err := getVersionFromGroup(&ReleasesStatus, vars["group"]); err == nil {
+	releases := product.getReleasesStatus()
+	version, err := getVersionFromGroup(product, releases, vars["group"])
+
+	// Use the channel the reader chose before, if the group has it
+	var channel string
+	if getVersionCookieName(product) != "" {
+		w.Header().Add("Vary", "Cookie")
+		if _, rememberedChannel := getRememberedVersion(r, product, releases); rememberedChannel != "" {
+			if rememberedVersion, channelErr := getVersionFromChannelAndGroup(releases, rememberedChannel, vars["group"]); channelErr == nil {
+				version, channel, err = rememberedVersion, rememberedChannel, nil
+			}
+		}
+	}
+
+	if err == nil {
 		log.Debugln(fmt.Sprintf("getVersionFromGroup: Got version - %s for x-redirect", version))
-		w.Header().Set("X-Accel-Redirect", fmt.Sprintf("%s%s/%s/%s", langPrefix, GlobalConfig.LocationVersions, VersionToURL(version), getDocPageURLRelative(r, true)))
+		// The default group is opened by the versionless URLs, so it is remembered only if the reader switched to it from another version
+		if vars["group"] != product.DefaultGroup || hasVersionCookie(r, product) {
+			rememberVersion(w, product, vars["group"], channel)
+		}
+		versionPath := fmt.Sprintf("%s%s/%s/%s", langPrefix, product.LocationVersions, VersionToURL(version), getDocPageURLRelative(r, true))
+		if !proxyVersion(w, r, product, releases, version, versionPath) && !serveVersion(w, r, product, version, getDocPageURLRelative(r, true)) {
+			w.Header().Set("X-Accel-Redirect", versionPath)
+		}
 	} else {
 		log.Debugln(fmt.Sprintf("getVersionFromGroup: Got error %e", err))
 		http.Redirect(w, r, fmt.Sprintf("%s/", langPrefix), 302)
@@ -65,10 +123,10 @@ func groupHandler(w http.ResponseWriter, r *http.Request) {
 // Temporarily redirect to specific version
 func groupChannelHandler(w http.ResponseWriter, r *http.Request) {
 	var version, URLToRedirect, langPrefix string
-	var re *regexp.Regexp
 	var err error
 
 	log.Debugln("Use handler - groupChannelHandler")
+	product := getProduct(r)
 
 	pageURLRelative := "/"
 	vars := mux.Vars(r)
@@ -76,25 +134,30 @@ func groupChannelHandler(w http.ResponseWriter, r *http.Request) {
 		langPrefix = fmt.Sprintf("/%s", vars["lang"])
 	}
 
-	_ = updateReleasesStatus()
-
 	if GlobalConfig.I18nType == "location" {
-		re = regexp.MustCompile(fmt.Sprintf("^/(ru|en)%s/[^/]+/(.+)$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(r.URL.RequestURI())
+		res := product.regexps.langGroupChannelPage.FindStringSubmatch(r.URL.RequestURI())
 		if res != nil {
 			pageURLRelative = res[2]
 		}
 	} else {
-		re = regexp.MustCompile(fmt.Sprintf("^%s/[^/]+/(.+)$", GlobalConfig.LocationVersions))
-		res := re.FindStringSubmatch(r.URL.RequestURI())
+		res := product.regexps.groupChannelPage.FindStringSubmatch(r.URL.RequestURI())
 		if res != nil {
 			pageURLRelative = res[1]
 		}
 	}
 
-	version, err = getVersionFromChannelAndGroup(&ReleasesStatus, vars["channel"], vars["group"])
+	releases := product.getReleasesStatus()
+
+	// Redirect aliases and mixed-case channel names to the canonical channel URL, e.g. /v1.2-early-access/ to /v1.2-ea/
+	if channel, ok := getCanonicalChannel(releases, vars["channel"]); ok && channel != vars["channel"] {
+		prefix := fmt.Sprintf("%s%s/%s-", langPrefix, product.LocationVersions, vars["group"])
+		http.Redirect(w, r, prefix+channel+strings.TrimPrefix(r.URL.RequestURI(), prefix+vars["channel"]), 301)
+		return
+	}
+
+	version, err = getVersionFromChannelAndGroup(releases, vars["channel"], vars["group"])
 	if err == nil {
-		URLToRedirect = fmt.Sprintf("%s%s/%s/%s", langPrefix, GlobalConfig.LocationVersions, VersionToURL(version), pageURLRelative)
+		URLToRedirect = fmt.Sprintf("%s%s/%s/%s", langPrefix, product.LocationVersions, VersionToURL(version), pageURLRelative)
 		err = validateURL(fmt.Sprintf("https://%s%s", r.Host, URLToRedirect))
 	}
 
@@ -102,10 +165,98 @@ func groupChannelHandler(w http.ResponseWriter, r *http.Request) {
 		log.Errorf("Error validating URL: %v, (original was https://%s/%s)", err.Error(), r.Host, r.URL.RequestURI())
 		notFoundHandler(w, r)
 	} else {
+		rememberVersion(w, product, vars["group"], vars["channel"])
 		http.Redirect(w, r, URLToRedirect, 302)
 	}
 }
 
+// Handles request to /<channel>/. E.g. /stable/
+// Temporarily redirect to the version of the channel in the newest (or the default) group, keeping the page path
+func channelHandler(w http.ResponseWriter, r *http.Request) {
+	var URLToRedirect, langPrefix string
+
+	log.Debugln("Use handler - channelHandler")
+	product := getProduct(r)
+
+	vars := mux.Vars(r)
+	if len(vars["lang"]) > 0 && GlobalConfig.I18nType == "location" {
+		langPrefix = fmt.Sprintf("/%s", vars["lang"])
+	}
+
+	prefix := fmt.Sprintf("%s%s/", langPrefix, product.LocationVersions)
+	pageURLRelative := strings.TrimPrefix(r.URL.RequestURI(), prefix+vars["channel"])
+	if !strings.HasPrefix(pageURLRelative, "/") {
+		pageURLRelative = "/" + pageURLRelative
+	}
+	releases := product.getReleasesStatus()
+
+	// Redirect aliases and mixed-case channel names to the canonical channel URL, e.g. /early-access/ to /ea/
+	if channel, ok := getCanonicalChannel(releases, vars["channel"]); ok && channel != vars["channel"] {
+		http.Redirect(w, r, prefix+channel+pageURLRelative, 301)
+		return
+	}
+
+	version, err := getVersionFromChannel(product, releases, vars["channel"])
+	if err == nil {
+		URLToRedirect = prefix + VersionToURL(version) + pageURLRelative
+		err = validateURL(fmt.Sprintf("https://%s%s", r.Host, URLToRedirect))
+	}
+
+	if err != nil {
+		log.Errorf("Error validating URL: %v, (original was https://%s/%s)", err.Error(), r.Host, r.URL.RequestURI())
+		notFoundHandler(w, r)
+	} else {
+		http.Redirect(w, r, URLToRedirect, 302)
+	}
+}
+
+// Handles request to /<version range>/. E.g. /v1.2.x/ or /~1.2/
+// Temporarily redirect to the newest known version in the range, keeping the page path
+func versionRangeHandler(w http.ResponseWriter, r *http.Request) {
+	var URLToRedirect, langPrefix, version string
+
+	log.Debugln("Use handler - versionRangeHandler")
+	product := getProduct(r)
+
+	vars := mux.Vars(r)
+	if len(vars["lang"]) > 0 && GlobalConfig.I18nType == "location" {
+		langPrefix = fmt.Sprintf("/%s", vars["lang"])
+	}
+
+	versionRange, err := ParseVersionRange(vars["range"])
+	if err == nil {
+		if version = getNewestVersionInRange(product.getReleasesStatus(), versionRange); version == "" {
+			err = fmt.Errorf("no versions in the range %s", vars["range"])
+		}
+	}
+	if err == nil {
+		URLToRedirect = fmt.Sprintf("%s%s/%s/%s", langPrefix, product.LocationVersions, VersionToURL(version), getDocPageURLRelative(r, true))
+		err = validateURL(fmt.Sprintf("https://%s%s", r.Host, URLToRedirect))
+	}
+
+	if err != nil {
+		log.Errorf("Error validating URL: %v, (original was https://%s/%s)", err.Error(), r.Host, r.URL.RequestURI())
+		notFoundHandler(w, r)
+	} else {
+		http.Redirect(w, r, URLToRedirect, 302)
+	}
+}
+
+// Proxy the request to the upstream of the version, if the version has it. Returns false if the version is not proxied.
+func proxyVersion(w http.ResponseWriter, r *http.Request, product *ProductType, releases *ReleasesStatusType, version, versionPath string) bool {
+	upstream, err := getVersionUpstream(product, releases, version)
+	if err != nil {
+		log.Errorf("Can't get the upstream for version %s: %s", version, err.Error())
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return true
+	}
+	if upstream == nil {
+		return false
+	}
+	proxyToUpstream(w, r, upstream, versionPath)
+	return true
+}
+
 // Healthcheck handler
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -114,9 +265,6 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 // Render templates
 func templateHandler(w http.ResponseWriter, r *http.Request) {
 	var tplPath string
-	if err := updateReleasesStatus(); err != nil {
-		log.Println(err)
-	}
 
 	templateData := templateDataType{
 		VersionItems:           []versionMenuItems{},
@@ -131,27 +279,39 @@ func templateHandler(w http.ResponseWriter, r *http.Request) {
 		MenuDocumentationLink:  "",
 	}
 
-	_ = templateData.getVersionMenuData(r)
+	// Templates can be shared between products, so the product is taken from the page URL
+	product := getProductByOriginalURI(r)
+	r = withProduct(r, product)
+	_ = templateData.getVersionMenuData(r, product.getReleasesStatus())
+	templateData.setRememberedVersion(r, product, product.getReleasesStatus())
 
 	switch GlobalConfig.I18nType {
 	case "location":
-		tplPath = getRootFilesPath() + r.URL.Path
+		tplPath = toFSPath(r.URL.Path)
 	case "separate-domain":
 		language := getLanguageFromDomainMap(r.Host)
 		log.Debugf("Detected %s language for the %s domain", language, r.Host)
-		tplPath = fmt.Sprintf("%s/%s%s", getRootFilesPath(), language, r.URL.Path)
+		tplPath = path.Join(language, toFSPath(r.URL.Path))
 	case "domain":
 		language := getLanguageFromDomain(r.Host)
 		log.Debugf("Detected %s language for the %s domain", language, r.Host)
-		tplPath = fmt.Sprintf("%s/%s%s", getRootFilesPath(), language, r.URL.Path)
+		tplPath = path.Join(language, toFSPath(r.URL.Path))
 	}
-	templateContent, err := ioutil.ReadFile(tplPath)
+	staticFiles := getStaticFS()
+	templateContent, err := fs.ReadFile(staticFiles, tplPath)
 	if err != nil {
 		log.Errorf("Can't read the template file %s: %s ", tplPath, err.Error())
 		http.Error(w, "<!-- Internal Server Error (template error) -->", 500)
+		return
 	}
+	setStaticLayerHeader(w, staticFiles, tplPath)
 
-	tpl := template.Must(template.New("template").Funcs(sprig.FuncMap()).Parse(string(templateContent)))
+	tpl, err := template.New("template").Funcs(sprig.FuncMap()).Parse(string(templateContent))
+	if err != nil {
+		log.Errorf("Can't parse the template file %s: %s ", tplPath, err.Error())
+		http.Error(w, "<!-- Internal Server Error (template error) -->", 500)
+		return
+	}
 
 	err = tpl.Execute(w, templateData)
 	if err != nil {
@@ -162,39 +322,38 @@ func templateHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getLanguageFromDomainMap(input string) string {
-	result := ""
 	host := strings.Split(input, ":")[0]
 	for lang, domain := range DomainMap {
-		// Get the first value from the map, to use as the default.
-		if result == "" {
-			result = lang
-		}
-
 		if host == domain || host == "www."+domain {
 			return lang
 		}
 	}
 
-	return result
+	// Use the default language for unknown domains
+	return GlobalConfig.DefaultLanguage
 }
 
+// Get the language by the domain prefix, e.g. "ru" for ru.example.com.
+// Domains without a language prefix use the default language.
 func getLanguageFromDomain(input string) string {
-	// Use en as the default language.
-	result := "en"
-
 	host := strings.Split(input, ":")[0]
 
-	if strings.HasPrefix(host, "ru.") || strings.HasPrefix(host, "www.ru.") {
-		result = "ru"
-	} else {
-		result = "en"
+	for _, lang := range GlobalConfig.Languages {
+		if strings.HasPrefix(host, lang+".") || strings.HasPrefix(host, "www."+lang+".") {
+			return lang
+		}
 	}
 
-	return result
+	return GlobalConfig.DefaultLanguage
 }
 
-func serveFilesHandler(fs http.FileSystem) http.Handler {
-	fsh := http.FileServer(fs)
+func serveFilesHandler(fsys fs.FS) http.Handler {
+	return serveFiles(fsys, http.HandlerFunc(notFoundHandler))
+}
+
+// Serve static files, the notFound handler is used for absent files and for directories without index.html
+func serveFiles(fsys fs.FS, notFound http.Handler) http.Handler {
+	fsh := http.FileServer(http.FS(fsys))
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		upath := r.URL.Path
 
@@ -203,23 +362,22 @@ func serveFilesHandler(fs http.FileSystem) http.Handler {
 			r.URL.Path = upath
 		}
 
-		upath = path.Clean(upath)
-		fileInfo, err := os.Stat(fmt.Sprintf("%v%s", fs, upath))
+		fileInfo, err := fs.Stat(fsys, toFSPath(upath))
 
 		if err != nil {
-			if os.IsNotExist(err) {
-				notFoundHandler(w, r)
-				return
-			}
+			notFound.ServeHTTP(w, r)
+			return
 		}
 
+		servedFile := toFSPath(upath)
 		if fileInfo.IsDir() {
-			indexFile := filepath.Join(upath, "index.html")
-			if _, err := os.Stat(fmt.Sprintf("%v%s", fs, indexFile)); err != nil {
-				notFoundHandler(w, r)
+			servedFile = path.Join(servedFile, "index.html")
+			if _, err := fs.Stat(fsys, servedFile); err != nil {
+				notFound.ServeHTTP(w, r)
 				return
 			}
 		}
+		setStaticLayerHeader(w, fsys, servedFile)
 		log.Tracef("Serving file " + r.URL.Path)
 		fsh.ServeHTTP(w, r)
 	})
@@ -229,35 +387,75 @@ func rootDocHandler(w http.ResponseWriter, r *http.Request) {
 	var redirectTo, langPrefix string
 
 	log.Debugln("Use handler - rootDocHandler")
+	product := getProduct(r)
 
 	vars := mux.Vars(r)
+	if len(vars["lang"]) == 0 && GlobalConfig.I18nType == "location" {
+		redirectToLanguage(w, r)
+		return
+	}
 	if len(vars["lang"]) > 0 && GlobalConfig.I18nType == "location" {
 		langPrefix = fmt.Sprintf("/%s", vars["lang"])
 	}
 
-	if hasSuffix, _ := regexp.MatchString(fmt.Sprintf("^/[^/]+%s/.+", GlobalConfig.LocationVersions), r.RequestURI); hasSuffix {
-		items := strings.Split(r.RequestURI, fmt.Sprintf("%s/", GlobalConfig.LocationVersions))
+	query := r.URL.Query()
+	_, reset := query[versionResetQueryParam]
+	if reset {
+		forgetVersion(w, product)
+		query.Del(versionResetQueryParam)
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	// In the proxy mode, requests to specific versions are proxied to the upstreams of the versions.
+	// In the standalone mode, they are served from the version directories.
+	re, versionIndex := product.regexps.versionPath, 1
+	if langPrefix != "" {
+		re, versionIndex = product.regexps.langVersionPath, 2
+	}
+	if res := re.FindStringSubmatch(r.URL.Path); res != nil {
+		if version, err := ParseVersion(URLToVersion(res[versionIndex])); err == nil && version.Parts == 3 {
+			if proxyVersion(w, r, product, product.getReleasesStatus(), version.Original, r.URL.Path) ||
+				serveVersion(w, r, product, version.Original, strings.TrimPrefix(r.URL.Path, res[0])) {
+				return
+			}
+		}
+	}
+
+	if product.regexps.prefixedPage.MatchString(r.RequestURI) {
+		items := strings.Split(r.RequestURI, fmt.Sprintf("%s/", product.LocationVersions))
 		if len(items) > 1 {
-			if isVersionOrChannel, _ := regexp.MatchString(fmt.Sprintf("^(%s|v[0-9]+.[0-9]+.[0-9]+([^/]+)?)[/]?", channelList), items[1]); isVersionOrChannel {
+			if isVersionOrChannel, _ := regexp.MatchString(fmt.Sprintf("^(%s|v[0-9]+.[0-9]+.[0-9]+([^/]+)?)[/]?", getChannelsURLRegexp(product.getReleasesStatus())), items[1]); isVersionOrChannel {
 				// We can't handle requests to specific version. They should be routed by balancer (create corresponding Ingress resource)
-				serveFilesHandler(http.Dir(getRootFilesPath())).ServeHTTP(w, r)
+				serveFilesHandler(getStaticFS()).ServeHTTP(w, r)
 			}
-			redirectTo = strings.Join(items[1:], fmt.Sprintf("%s%s/", langPrefix, GlobalConfig.LocationVersions))
+			redirectTo = strings.Join(items[1:], fmt.Sprintf("%s%s/", langPrefix, product.LocationVersions))
 		}
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("%s%s/%s/%s", langPrefix, GlobalConfig.LocationVersions, GlobalConfig.DefaultGroup, redirectTo), 301)
+	// Redirect to the version the reader chose before, unless it is reset
+	group, code := product.DefaultGroup, 301
+	if getVersionCookieName(product) != "" {
+		w.Header().Add("Vary", "Cookie")
+		code = 302
+		if rememberedGroup, rememberedChannel := getRememberedVersion(r, product, product.getReleasesStatus()); rememberedGroup != "" && !reset {
+			group = rememberedGroup
+			if rememberedChannel != "" {
+				group = fmt.Sprintf("%s-%s", rememberedGroup, rememberedChannel)
+			}
+		}
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s%s/%s/%s", langPrefix, product.LocationVersions, group, redirectTo), code)
 }
 
 // Redirect to root documentation if request not matches any location (override 404 response)
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	var re *regexp.Regexp
-	lang := "en"
+	lang := GlobalConfig.DefaultLanguage
 
 	switch GlobalConfig.I18nType {
 	case "location":
-		re = regexp.MustCompile(`^/(ru|en)/.*$`)
-		res := re.FindStringSubmatch(r.URL.RequestURI())
+		res := languagePageRegexp.FindStringSubmatch(r.URL.RequestURI())
 		if res != nil {
 			lang = res[1]
 		}
@@ -267,9 +465,11 @@ func notFoundHandler(w http.ResponseWriter, r *http.Request) {
 		lang = getLanguageFromDomain(r.Host)
 	}
 
+	staticFiles := getStaticFS()
+	page404Path := toFSPath(fmt.Sprintf("%s/404.html", lang))
+	setStaticLayerHeader(w, staticFiles, page404Path)
 	w.WriteHeader(http.StatusNotFound)
-	page404File, err := os.Open(fmt.Sprintf("%s/%s/404.html", getRootFilesPath(), lang))
-	defer page404File.Close()
+	page404File, err := staticFiles.Open(page404Path)
 	if err != nil {
 		// 404.html file not found! Send the fallback page...
 		log.Error("404.html file not found")
@@ -298,5 +498,6 @@ func notFoundHandler(w http.ResponseWriter, r *http.Request) {
 </html>`, 404)
 		return
 	}
+	defer page404File.Close()
 	io.Copy(w, page404File)
 }
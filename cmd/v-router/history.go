@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Get the channel history sorted from the newest promotion to the oldest one
+func (c *ChannelType) SortedHistory() []ChannelHistoryItem {
+	if len(c.History) == 0 {
+		return nil
+	}
+	result := append([]ChannelHistoryItem{}, c.History...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Promoted.After(result[j].Promoted)
+	})
+	return result
+}
+
+// Get the version the channel pointed to at the specified time, according to the channel history.
+// The history should contain the current version too. Returns an empty string if the version is unknown.
+func (c *ChannelType) VersionAt(t time.Time) string {
+	for _, item := range c.SortedHistory() {
+		if !item.Promoted.After(t) {
+			return item.Version
+		}
+	}
+	return ""
+}
+
+// Parse the time of a channel history request, e.g. "2022-05-01" or "2022-05-01T10:00:00Z"
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		// The whole day is included
+		return t.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Get versions of the channels at the specified time, by groups and channels
+func getVersionsAt(releases *ReleasesStatusType, t time.Time) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			if version := channel.VersionAt(t); version != "" {
+				if _, ok := result[group.Name]; !ok {
+					result[group.Name] = make(map[string]string)
+				}
+				result[group.Name][channel.Name] = version
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestChannelHistory(t *testing.T) {
+	releases, err := decodeReleasesStatus([]byte(`groups:
+ - name: v1
+   channels:
+    - name: stable
+      version: v1.3.0
+      history:
+       - version: v1.1.0
+         promoted: 2022-01-10
+       - version: v1.3.0
+         promoted: 2022-03-01T12:00:00Z
+       - version: v1.2.0
+         promoted: 2022-02-01
+`), "yaml", "channels.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channel := releases.Groups[0].Channels[0]
+	if history := channel.SortedHistory(); len(history) != 3 || history[0].Version != "v1.3.0" || history[2].Version != "v1.1.0" {
+		t.Errorf("Wrong history order: %+v", history)
+	}
+
+	tests := map[string]string{
+		"2022-01-01":           "",
+		"2022-01-10":           "v1.1.0",
+		"2022-02-15":           "v1.2.0",
+		"2022-03-01T11:00:00Z": "v1.2.0",
+		"2022-03-01":           "v1.3.0",
+	}
+	for date, expected := range tests {
+		at, err := parseHistoryTime(date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version := getVersionsAt(releases, at)["v1"]["stable"]; version != expected {
+			t.Errorf("Wrong version at %s, expected %q, got %q", date, expected, version)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query parameter overriding the negotiated language, e.g. /documentation/?lang=ru
+const languageQueryParam = "lang"
+
+// Language range of the Accept-Language header with its weight, e.g. "de-CH;q=0.8"
+type acceptLanguageItem struct {
+	Tag    string
+	Weight float64
+}
+
+// Parse the Accept-Language header. Items are ordered by the weight in the descending order, items with the zero weight are skipped.
+func parseAcceptLanguage(header string) (items []acceptLanguageItem) {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		item := acceptLanguageItem{Tag: strings.TrimSpace(fields[0]), Weight: 1}
+		if item.Tag == "" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				weight, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					weight = 0
+				}
+				item.Weight = weight
+			}
+		}
+		if item.Weight > 0 {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Weight > items[j].Weight
+	})
+	return
+}
+
+// Get the configured language matching the language tag, e.g. "zh" for "zh-CN".
+// The exact match is preferred, then the primary language subtags are compared.
+func matchLanguage(tag string) string {
+	for _, lang := range GlobalConfig.Languages {
+		if strings.EqualFold(lang, tag) {
+			return lang
+		}
+	}
+	primary := strings.SplitN(tag, "-", 2)[0]
+	for _, lang := range GlobalConfig.Languages {
+		if strings.EqualFold(strings.SplitN(lang, "-", 2)[0], primary) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// Choose the language for a request without a language in the URL.
+// The query parameter takes precedence over the language cookie, and the cookie takes precedence over the Accept-Language header.
+func negotiateLanguage(r *http.Request) string {
+	if lang := matchLanguage(r.URL.Query().Get(languageQueryParam)); lang != "" {
+		return lang
+	}
+	if GlobalConfig.LanguageCookie != "" {
+		if cookie, err := r.Cookie(GlobalConfig.LanguageCookie); err == nil {
+			if lang := matchLanguage(cookie.Value); lang != "" {
+				return lang
+			}
+		}
+	}
+	for _, item := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if lang := matchLanguage(item.Tag); lang != "" {
+			return lang
+		}
+	}
+	return GlobalConfig.DefaultLanguage
+}
+
+// Page in the language directory, e.g. /ru/index.html. Compiled by newRouter().
+var languagePageRegexp *regexp.Regexp
+
+func compileLanguageRegexps() {
+	languagePageRegexp = regexp.MustCompile(fmt.Sprintf("^/(%s)/.*$", languagesRegexp()))
+}
+
+// Redirect the request without a language in the URL to the same URL with the negotiated language,
+// e.g. /documentation/ to /ru/documentation/. Used in the location localization mode.
+func redirectToLanguage(w http.ResponseWriter, r *http.Request) {
+	lang := negotiateLanguage(r)
+
+	query := r.URL.Query()
+	if override := matchLanguage(query.Get(languageQueryParam)); override != "" && GlobalConfig.LanguageCookie != "" {
+		// Remember the explicitly chosen language
+		http.SetCookie(w, &http.Cookie{
+			Name:   GlobalConfig.LanguageCookie,
+			Value:  override,
+			Path:   "/",
+			MaxAge: int(cookieMaxAge.Seconds()),
+		})
+	}
+	query.Del(languageQueryParam)
+
+	redirectTo := url.URL{Path: fmt.Sprintf("/%s%s", lang, r.URL.Path), RawQuery: query.Encode()}
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", "Cookie")
+	log.Debugln(fmt.Sprintf("Chose the %s language for %s", lang, r.URL.RequestURI()))
+	http.Redirect(w, r, redirectTo.String(), 302)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	GlobalConfig.Languages = []string{"en", "ru", "zh", "pt-BR"}
+	GlobalConfig.DefaultLanguage = "en"
+	GlobalConfig.LanguageCookie = "lang"
+	defer func() { GlobalConfig.Languages = []string{"en", "ru"} }()
+
+	tests := []struct {
+		url, acceptLanguage, cookie, expected string
+	}{
+		{"/documentation/", "", "", "en"},
+		{"/documentation/", "fr-FR,fr;q=0.9,ru;q=0.8,en;q=0.7", "", "ru"},
+		{"/documentation/", "en;q=0.5, zh-CN", "", "zh"},
+		{"/documentation/", "pt-br", "", "pt-BR"},
+		{"/documentation/", "pt-PT,ru;q=0", "", "pt-BR"},
+		{"/documentation/", "ru", "zh", "zh"},
+		{"/documentation/", "ru", "fr", "ru"},
+		{"/documentation/?lang=en", "ru", "zh", "en"},
+		{"/documentation/?lang=fr", "ru", "", "ru"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: test.cookie})
+		}
+		if lang := negotiateLanguage(r); lang != test.expected {
+			t.Errorf("Wrong language for %s (Accept-Language: %q, cookie: %q), expected %s, got %s", test.url, test.acceptLanguage, test.cookie, test.expected, lang)
+		}
+	}
+}
+
+func TestLanguageRedirect(t *testing.T) {
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.LanguageCookie = "lang"
+	newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.0"}}}}})
+	staticFS = fstest.MapFS{"en/404.html": {Data: []byte("not found")}}
+	defer func() { staticFS = nil }()
+	router := newRouter()
+
+	tests := []struct {
+		url, acceptLanguage, expected string
+	}{
+		{"/", "ru-RU,ru;q=0.9", "/ru/"},
+		{"/documentation", "en", "/en/documentation"},
+		{"/documentation/v1.2-stable/cli/?q=1", "ru", "/ru/documentation/v1.2-stable/cli/?q=1"},
+		{"/documentation/?lang=ru", "en", "/ru/documentation/"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		r.Header.Set("Accept-Language", test.acceptLanguage)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if location := recorder.Header().Get("Location"); recorder.Code != http.StatusFound || location != test.expected {
+			t.Errorf("%s: expected redirect to %s, got %d %s", test.url, test.expected, recorder.Code, location)
+		}
+		if vary := recorder.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Accept-Language" {
+			t.Errorf("%s: Vary header is not set: %v", test.url, vary)
+		}
+	}
+
+	// Other URLs without a language get the 404 page
+	for _, url := range []string{"/favicon.ico", "/assets/missing.css"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		if recorder.Code != http.StatusNotFound || recorder.Header().Get("Location") != "" {
+			t.Errorf("%s: expected 404, got %d %s", url, recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+
+	// The explicitly chosen language is remembered
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/documentation/?lang=ru", nil))
+	if cookies := recorder.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "lang" || cookies[0].Value != "ru" {
+		t.Errorf("Language cookie is not set: %v", cookies)
+	}
+}
@@ -9,41 +9,56 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"time"
 )
 
 var GlobalConfig GlobalConfigType
-var channelList string
 
 func newRouter() *mux.Router {
 	var langPrefix string
 	r := mux.NewRouter()
 
-	staticFileDirectory := http.Dir(getRootFilesPath())
-
 	if GlobalConfig.I18nType == "location" {
-		langPrefix = "/{lang:ru|en}"
-	}
-
-	channelList = "alpha|beta|ea|stable|rock-solid"
-	if GlobalConfig.ShowLatestChannel {
-		channelList = "latest|" + channelList
+		langPrefix = fmt.Sprintf("/{lang:%s}", languagesRegexp())
 	}
 
 	r.PathPrefix("/status").HandlerFunc(statusHandler)
 	r.PathPrefix("/health").HandlerFunc(healthCheckHandler)
 
-	r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+.[0-9]+}-{channel:%s}/", langPrefix, GlobalConfig.LocationVersions, channelList)).HandlerFunc(groupChannelHandler)
-	r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+}-{channel:%s}/", langPrefix, GlobalConfig.LocationVersions, channelList)).HandlerFunc(groupChannelHandler)
-	r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+}/", langPrefix, GlobalConfig.LocationVersions)).HandlerFunc(groupHandler)
-	r.PathPrefix(fmt.Sprintf("%s%s/", langPrefix, GlobalConfig.LocationVersions)).HandlerFunc(rootDocHandler)
-	r.HandleFunc(fmt.Sprintf("%s%s", langPrefix, GlobalConfig.LocationVersions), rootDocHandler)
-	r.PathPrefix(fmt.Sprintf("%s%s/", langPrefix, GlobalConfig.PathTpls)).HandlerFunc(templateHandler)
-	r.PathPrefix(fmt.Sprintf("%s/", GlobalConfig.PathTpls)).HandlerFunc(templateHandler)
+	// Create the default product if products are not loaded yet
+	getDefaultProduct()
+	compileLanguageRegexps()
+	templateLocations := make(map[string]bool)
+	for _, product := range getProductsByLocation() {
+		product.compileRegexps()
+		r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+.[0-9]+}-{channel}/", langPrefix, product.LocationVersions)).MatcherFunc(matchChannel(product)).HandlerFunc(productHandler(product, groupChannelHandler))
+		r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+}-{channel}/", langPrefix, product.LocationVersions)).MatcherFunc(matchChannel(product)).HandlerFunc(productHandler(product, groupChannelHandler))
+		r.PathPrefix(fmt.Sprintf("%s%s/{group:v[0-9]+}/", langPrefix, product.LocationVersions)).HandlerFunc(productHandler(product, groupHandler))
+		r.PathPrefix(fmt.Sprintf("%s%s/{range}/", langPrefix, product.LocationVersions)).MatcherFunc(matchVersionRange(product)).HandlerFunc(productHandler(product, versionRangeHandler))
+		r.PathPrefix(fmt.Sprintf("%s%s/{channel}", langPrefix, product.LocationVersions)).MatcherFunc(matchGrouplessChannel(product)).HandlerFunc(productHandler(product, channelHandler))
+		r.PathPrefix(fmt.Sprintf("%s%s/", langPrefix, product.LocationVersions)).HandlerFunc(productHandler(product, rootDocHandler))
+		r.HandleFunc(fmt.Sprintf("%s%s", langPrefix, product.LocationVersions), productHandler(product, rootDocHandler))
+		if GlobalConfig.I18nType == "location" {
+			// URLs without a language are redirected to the negotiated language
+			r.PathPrefix(fmt.Sprintf("%s/", product.LocationVersions)).HandlerFunc(productHandler(product, rootDocHandler))
+			r.HandleFunc(product.LocationVersions, productHandler(product, rootDocHandler))
+		}
+
+		// Products can share templates, the template handler finds the product by the page URL
+		if !templateLocations[product.PathTpls] {
+			templateLocations[product.PathTpls] = true
+			r.PathPrefix(fmt.Sprintf("%s%s/", langPrefix, product.PathTpls)).HandlerFunc(productHandler(product, templateHandler))
+			r.PathPrefix(fmt.Sprintf("%s/", product.PathTpls)).HandlerFunc(productHandler(product, templateHandler))
+		}
+	}
 
 	r.Path("/404.html").HandlerFunc(notFoundHandler)
+	if GlobalConfig.I18nType == "location" {
+		r.Path("/").HandlerFunc(redirectToLanguage)
+	}
 
-	r.PathPrefix("/").Handler(serveFilesHandler(staticFileDirectory))
+	r.PathPrefix("/").Handler(serveFilesHandler(getStaticFS()))
 
 	r.Use(LoggingMiddleware)
 
@@ -52,22 +67,77 @@ func newRouter() *mux.Router {
 	return r
 }
 
+// Get the matcher checking that the /<group>-<channel>/ URL of the product refers to a known channel or channel alias.
+// Channels are taken from the current channels data, so the routes don't need to be rebuilt when the channel list changes.
+func matchChannel(product *ProductType) mux.MatcherFunc {
+	re := regexp.MustCompile(fmt.Sprintf("%s/v[0-9]+(.[0-9]+)?-([^/]+)/", regexp.QuoteMeta(product.LocationVersions)))
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		res := re.FindStringSubmatch(r.URL.Path)
+		if res == nil {
+			return false
+		}
+		_, ok := getCanonicalChannel(product.getReleasesStatus(), res[2])
+		return ok
+	}
+}
+
+// Get the matcher checking that the /<channel>/ URL of the product refers to a known channel or channel alias.
+// The 'latest' channel is not resolved, it is served as a usual directory.
+func matchGrouplessChannel(product *ProductType) mux.MatcherFunc {
+	re := regexp.MustCompile(fmt.Sprintf("%s/([^/]+)(/|$)", regexp.QuoteMeta(product.LocationVersions)))
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		res := re.FindStringSubmatch(r.URL.Path)
+		if res == nil {
+			return false
+		}
+		releases := product.getReleasesStatus()
+		channel, ok := getCanonicalChannel(releases, res[1])
+		return ok && contains(getChannelsListReverseStability(releases), channel)
+	}
+}
+
+// Get the matcher checking that the version URL of the product is a version range, e.g. /v1.2.x/
+func matchVersionRange(product *ProductType) mux.MatcherFunc {
+	re := regexp.MustCompile(fmt.Sprintf("%s/([^/]+)/", regexp.QuoteMeta(product.LocationVersions)))
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		res := re.FindStringSubmatch(r.URL.Path)
+		if res == nil {
+			return false
+		}
+		_, err := ParseVersionRange(res[1])
+		return err == nil
+	}
+}
+
 func main() {
 	err := envconfig.Process("VROUTER", &GlobalConfig)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
+	if err := loadProducts(); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validateCommand(os.Args[2:], os.Stdout))
+	}
+
 	Setup()
 	ValidateConfig()
 	printConfiguration()
+	updateReleasesStatus()
+	go watchReleasesStatus(GlobalConfig.ChannelsReloadInterval)
+	if GlobalConfig.Standalone {
+		indexVersionArchives()
+	}
 
 	r := newRouter()
 
 	srv := &http.Server{
 		Handler:      r,
 		Addr:         fmt.Sprintf("%s:%s", GlobalConfig.ListenAddress, GlobalConfig.ListenPort),
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: getServerWriteTimeout(),
 		ReadTimeout:  15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
@@ -90,5 +160,6 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("shutdown failed:%+s", err)
 	}
+	closeArchives()
 	log.Infoln("Shutting down...")
 }
@@ -1,13 +1,68 @@
 package main
 
 import (
+	"github.com/kelseyhightower/envconfig"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"testing/fstest"
 )
 
+// The default configuration, restored after tests using newTestProduct
+var defaultConfig GlobalConfigType
+
+// Use the default configuration in tests
+func TestMain(m *testing.M) {
+	if err := envconfig.Process("VROUTER", &GlobalConfig); err != nil {
+		panic(err)
+	}
+	defaultConfig = GlobalConfig
+	if err := initProxy(); err != nil {
+		panic(err)
+	}
+	if err := initStandalone(); err != nil {
+		panic(err)
+	}
+	compileLanguageRegexps()
+	os.Exit(m.Run())
+}
+
+// Create the docs product with the releases and make it the only product.
+// The products and the default configuration are restored when the test finishes, so the test can change GlobalConfig.
+func newTestProduct(t *testing.T, releases *ReleasesStatusType) *ProductType {
+	product := &ProductType{Name: "docs", LocationVersions: "/documentation", DefaultGroup: "v1", DefaultChannel: "stable", PathTpls: "/includes"}
+	product.releases.Store(releases)
+	product.compileRegexps()
+	Products = []*ProductType{product}
+	restoreConfig(t)
+	return product
+}
+
+// Restore the products and the default configuration when the test finishes
+func restoreConfig(t *testing.T) {
+	t.Cleanup(func() {
+		Products = nil
+		GlobalConfig = defaultConfig
+		_ = initProxy()
+		_ = initStandalone()
+		compileLanguageRegexps()
+	})
+}
+
+// In-memory static files and templates
+var testStaticFS = fstest.MapFS{
+	"index.html":                    {Data: []byte("<html><body>Documentation</body></html>")},
+	"en/404.html":                   {Data: []byte("<html><body>Not found</body></html>")},
+	"en/includes/version-menu.html": {Data: []byte(`<ul>{{ range .VersionItems }}<li>{{ .Group }}</li>{{ end }}</ul>`)},
+}
+
 func TestHandler(t *testing.T) {
-	req, err := http.NewRequest("GET", "/", nil)
+	GlobalConfig.I18nType = "domain"
+	staticFS = testStaticFS
+	defer func() { staticFS = nil }()
+
+	req, err := http.NewRequest("GET", "/includes/version-menu.html", nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -34,6 +89,10 @@ func TestHandler(t *testing.T) {
 }
 
 func TestStaticFileServer(t *testing.T) {
+	GlobalConfig.I18nType = "domain"
+	staticFS = testStaticFS
+	defer func() { staticFS = nil }()
+
 	r := newRouter()
 	mockServer := httptest.NewServer(r)
 
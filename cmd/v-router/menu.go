@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Group of the hierarchical version menu
+type menuGroupType struct {
+	Name          string
+	Title         string // Display title, e.g. "1.2 LTS"
+	Badges        []string
+	SupportStatus string
+	IsDeprecated  bool
+	EOLDate       string
+	IsCurrent     bool
+	Versions      []menuVersionType // Versions of the group channels, from the most stable channel to the least stable one
+}
+
+// Version of the group in the hierarchical version menu. Channels with the same version are collapsed into one entry.
+type menuVersionType struct {
+	Version    string
+	VersionURL string // Base URL for the version without a leading /, e.g. 'v1.2.3-plus-fix6'
+	Channels   []menuChannelType
+	IsCurrent  bool
+}
+
+// Channel of the version in the hierarchical version menu
+type menuChannelType struct {
+	Name      string
+	History   []ChannelHistoryItem
+	IsCurrent bool
+}
+
+// Get the display title of the group, e.g. "1.2 LTS". The group name without the leading 'v' by default.
+func getGroupTitle(group *ReleaseType) string {
+	if group.Title != "" {
+		return group.Title
+	}
+	return strings.TrimPrefix(group.Name, "v")
+}
+
+// Build the hierarchical version menu (groups → versions → channels) for groups shown in menus.
+// The current group, version and channel are flagged.
+func getMenuTree(releases *ReleasesStatusType, currentGroup *ReleaseType, currentVersion, currentChannel string) (tree []menuGroupType) {
+	if currentChannel == "" {
+		currentChannel, _ = getChannelAndGroupFromVersion(releases, currentVersion)
+	}
+
+	now := time.Now()
+	for _, name := range getMenuGroups(releases) {
+		group := getReleaseGroup(releases, name)
+		menuGroup := menuGroupType{
+			Name:          name,
+			Title:         getGroupTitle(group),
+			Badges:        group.Badges,
+			SupportStatus: group.SupportStatusAt(now),
+			EOLDate:       group.EOLDate(),
+			IsCurrent:     currentGroup != nil && currentGroup.Name == name,
+		}
+		menuGroup.IsDeprecated = menuGroup.SupportStatus != supportStatusSupported
+
+		for _, channel := range getChannelsListReverseStability(releases) {
+			for _, channelItem := range group.Channels {
+				if channelItem.Name != channel {
+					continue
+				}
+
+				index := -1
+				for i := range menuGroup.Versions {
+					if menuGroup.Versions[i].Version == channelItem.Version {
+						index = i
+					}
+				}
+				if index < 0 {
+					menuGroup.Versions = append(menuGroup.Versions, menuVersionType{
+						Version:    channelItem.Version,
+						VersionURL: VersionToURL(channelItem.Version),
+						IsCurrent:  menuGroup.IsCurrent && channelItem.Version == currentVersion,
+					})
+					index = len(menuGroup.Versions) - 1
+				}
+				menuGroup.Versions[index].Channels = append(menuGroup.Versions[index].Channels, menuChannelType{
+					Name:      channelItem.Name,
+					History:   channelItem.SortedHistory(),
+					IsCurrent: menuGroup.Versions[index].IsCurrent && channelItem.Name == currentChannel,
+				})
+			}
+		}
+		tree = append(tree, menuGroup)
+	}
+	return
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMenuTree(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.I18nType = "domain"
+
+	releases := &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1.1", Channels: []ChannelType{{Name: "alpha", Version: "v1.1.6"}, {Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.1.5"}}},
+		{Name: "v1.2", Channels: []ChannelType{{Name: "alpha", Version: "v1.2.1"}}, Title: "1.2 (new)"},
+		{Name: "v1.3", Channels: []ChannelType{{Name: "alpha", Version: "v1.3.0"}}, Hidden: true},
+	}}
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v1.1"
+
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/documentation/v1.1-ea/reference/")
+	menu := templateDataType{}
+	_ = menu.getChannelMenuData(withProduct(r, product), releases)
+
+	if len(menu.MenuGroups) != 2 || menu.MenuGroups[0].Name != "v1.2" || menu.MenuGroups[1].Name != "v1.1" {
+		t.Fatalf("Wrong menu groups: %+v", menu.MenuGroups)
+	}
+	if group := menu.MenuGroups[0]; group.IsCurrent || group.Title != "1.2 (new)" || len(group.Versions) != 1 {
+		t.Errorf("Wrong v1.2 menu group: %+v", group)
+	}
+
+	group := menu.MenuGroups[1]
+	if !group.IsCurrent || len(group.Versions) != 2 {
+		t.Fatalf("Wrong v1.1 menu group: %+v", group)
+	}
+	// The stable and ea channels have the same version and are collapsed into one entry
+	collapsed := group.Versions[0]
+	if collapsed.Version != "v1.1.5" || !collapsed.IsCurrent || len(collapsed.Channels) != 2 {
+		t.Fatalf("Wrong collapsed version: %+v", collapsed)
+	}
+	if collapsed.Channels[0].Name != "stable" || collapsed.Channels[0].IsCurrent || collapsed.Channels[1].Name != "ea" || !collapsed.Channels[1].IsCurrent {
+		t.Errorf("Wrong channels of the collapsed version: %+v", collapsed.Channels)
+	}
+	if version := group.Versions[1]; version.Version != "v1.1.6" || version.IsCurrent || len(version.Channels) != 1 || version.Channels[0].Name != "alpha" {
+		t.Errorf("Wrong v1.1.6 version: %+v", version)
+	}
+}
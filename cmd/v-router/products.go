@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync/atomic"
+)
+
+// Documentation product served by the router. Each product has its own URL location, channels data, defaults and templates.
+type ProductType struct {
+	Name             string `json:"name"`
+	LocationVersions string `json:"locationVersions" yaml:"locationVersions"` // URL-location of versions, e.g. /werf/documentation
+	PathChannelsFile string `json:"pathChannelsFile" yaml:"pathChannelsFile"` // Channels file, directory or URL
+	ChannelsData     string `json:"channelsData" yaml:"channelsData"`         // Inline channels data
+	DefaultGroup     string `json:"defaultGroup" yaml:"defaultGroup"`
+	DefaultChannel   string `json:"defaultChannel" yaml:"defaultChannel"`
+	PathTpls         string `json:"pathTpls" yaml:"pathTpls"` // Templates directory and URL-location
+
+	source    ChannelSource
+	releases  atomic.Value        // *ReleasesStatusType, the stored data is never modified
+	loadState atomic.Value        // *releasesLoadStateType
+	regexps   *productRegexpsType // Compiled by newRouter()
+}
+
+// Regexps of the product URLs, compiled by newRouter().
+// The lang* regexps are used in the location mode and have the language as the first group.
+type productRegexpsType struct {
+	path                 *regexp.Regexp // Any URL of the product
+	langPath             *regexp.Regexp
+	langPage             *regexp.Regexp // Page of a version, e.g. /en/documentation/v1.2/cli/
+	langPageRelative     *regexp.Regexp // Page with an optional version, e.g. /en/documentation/v1.2/cli/ or /en/index.html
+	pageRelative         *regexp.Regexp
+	langVersionURL       *regexp.Regexp // Version of the page, e.g. v1.2 for /en/documentation/v1.2/cli/
+	versionURL           *regexp.Regexp
+	langGroupChannelPage *regexp.Regexp // Page of a group channel, e.g. cli/ for /en/documentation/v1.2-ea/cli/
+	groupChannelPage     *regexp.Regexp
+	langVersionPath      *regexp.Regexp // Version of the URL path, e.g. v1.2.3 for /en/documentation/v1.2.3/cli/
+	versionPath          *regexp.Regexp
+	prefixedPage         *regexp.Regexp // Page with a prefix, e.g. /en/documentation/cli/
+}
+
+// Compile the regexps of the product URLs
+func (p *ProductType) compileRegexps() {
+	languages := languagesRegexp()
+	p.regexps = &productRegexpsType{
+		path:                 regexp.MustCompile(fmt.Sprintf("^%s(/|$)", regexp.QuoteMeta(p.LocationVersions))),
+		langPath:             regexp.MustCompile(fmt.Sprintf("^(/(%s))?%s(/|$)", languages, regexp.QuoteMeta(p.LocationVersions))),
+		langPage:             regexp.MustCompile(fmt.Sprintf("^/(%s)%s/.+$", languages, p.LocationVersions)),
+		langPageRelative:     regexp.MustCompile(fmt.Sprintf("^/(%s)(%s/[^/]+)?/(.*)$", languages, p.LocationVersions)),
+		pageRelative:         regexp.MustCompile(fmt.Sprintf("^%s/[^/]+/(.*)$", p.LocationVersions)),
+		langVersionURL:       regexp.MustCompile(fmt.Sprintf("^/(%s)%s/([^/]+)/?.*$", languages, p.LocationVersions)),
+		versionURL:           regexp.MustCompile(fmt.Sprintf("^%s/([^/]+)/?.*$", p.LocationVersions)),
+		langGroupChannelPage: regexp.MustCompile(fmt.Sprintf("^/(%s)%s/[^/]+/(.+)$", languages, p.LocationVersions)),
+		groupChannelPage:     regexp.MustCompile(fmt.Sprintf("^%s/[^/]+/(.+)$", p.LocationVersions)),
+		langVersionPath:      regexp.MustCompile(fmt.Sprintf("^/(%s)%s/([^/]+)", languages, regexp.QuoteMeta(p.LocationVersions))),
+		versionPath:          regexp.MustCompile(fmt.Sprintf("^%s/([^/]+)", regexp.QuoteMeta(p.LocationVersions))),
+		prefixedPage:         regexp.MustCompile(fmt.Sprintf("^/[^/]+%s/.+", p.LocationVersions)),
+	}
+}
+
+type productsFileType struct {
+	Products []*ProductType
+}
+
+type contextKey string
+
+const productContextKey contextKey = "product"
+
+// Products served by the router in the declaration order, the first one is the default product
+var Products []*ProductType
+
+// Create the product from the VROUTER_* environment variables. Used if no products file is specified.
+func newDefaultProduct() *ProductType {
+	product := &ProductType{
+		Name:             "default",
+		LocationVersions: GlobalConfig.LocationVersions,
+		PathChannelsFile: GlobalConfig.PathChannelsFile,
+		ChannelsData:     GlobalConfig.ChannelsData,
+		DefaultGroup:     GlobalConfig.DefaultGroup,
+		DefaultChannel:   GlobalConfig.DefaultChannel,
+		PathTpls:         GlobalConfig.PathTpls,
+	}
+	product.compileRegexps()
+	return product
+}
+
+// Load products from the products file, or create the default product if the file is not specified
+func loadProducts() error {
+	if GlobalConfig.PathProductsFile == "" {
+		Products = []*ProductType{newDefaultProduct()}
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(GlobalConfig.PathProductsFile)
+	if err != nil {
+		return fmt.Errorf("can't read products file %s (%s)", GlobalConfig.PathProductsFile, err.Error())
+	}
+	productsFile := productsFileType{}
+	switch getChannelsFormat(GlobalConfig.PathProductsFile, "") {
+	case "json":
+		err = unmarshalJSON(data, &productsFile)
+	case "yaml":
+		err = unmarshalYAML(data, &productsFile)
+	default:
+		err = fmt.Errorf("unknown products file format, use the .json, .yaml or .yml extension")
+	}
+	if err != nil {
+		return fmt.Errorf("can't decode products file %s (%s)", GlobalConfig.PathProductsFile, err.Error())
+	}
+	if len(productsFile.Products) == 0 {
+		return errors.New("no products found in the products file")
+	}
+
+	names := make(map[string]bool)
+	locations := make(map[string]bool)
+	for _, product := range productsFile.Products {
+		if product.Name == "" || product.LocationVersions == "" {
+			return errors.New("product name and URL-location (locationVersions) must be specified for every product")
+		}
+		if names[product.Name] || locations[product.LocationVersions] {
+			return fmt.Errorf("duplicate product %s (%s)", product.Name, product.LocationVersions)
+		}
+		names[product.Name] = true
+		locations[product.LocationVersions] = true
+
+		if product.DefaultGroup == "" {
+			product.DefaultGroup = GlobalConfig.DefaultGroup
+		}
+		if product.DefaultChannel == "" {
+			product.DefaultChannel = GlobalConfig.DefaultChannel
+		}
+		if product.PathTpls == "" {
+			product.PathTpls = GlobalConfig.PathTpls
+		}
+		product.compileRegexps()
+	}
+
+	Products = productsFile.Products
+	return nil
+}
+
+// Get the products ordered by the URL-location length, longer locations first.
+// So /werf/documentation is not taken for /documentation with a language prefix. Products keeps the declaration order.
+func getProductsByLocation() []*ProductType {
+	products := append([]*ProductType(nil), Products...)
+	sort.SliceStable(products, func(i, j int) bool {
+		return len(products[i].LocationVersions) > len(products[j].LocationVersions)
+	})
+	return products
+}
+
+// Get the product used when the request doesn't belong to any product, e.g. for /status. It is the first declared product.
+func getDefaultProduct() *ProductType {
+	if len(Products) == 0 {
+		Products = []*ProductType{newDefaultProduct()}
+	}
+	return Products[0]
+}
+
+// Get the product by name
+func getProductByName(name string) *ProductType {
+	for _, product := range Products {
+		if product.Name == name {
+			return product
+		}
+	}
+	return nil
+}
+
+// Get the product the URL path belongs to, e.g. the werf product for /en/werf/documentation/v1.2/
+func getProductByPath(path string) *ProductType {
+	for _, product := range getProductsByLocation() {
+		re := product.regexps.path
+		if GlobalConfig.I18nType == "location" {
+			re = product.regexps.langPath
+		}
+		if re.MatchString(path) {
+			return product
+		}
+	}
+	return nil
+}
+
+// Get the product the request was routed to
+func getProduct(r *http.Request) *ProductType {
+	if product, ok := r.Context().Value(productContextKey).(*ProductType); ok {
+		return product
+	}
+	return getDefaultProduct()
+}
+
+// Get the product of the page a template is rendered for, by the x-original-uri header.
+// Products can share templates, so the template URL is not enough.
+func getProductByOriginalURI(r *http.Request) *ProductType {
+	if originalURI, err := url.Parse(r.Header.Get("x-original-uri")); err == nil {
+		if product := getProductByPath(originalURI.Path); product != nil {
+			return product
+		}
+	}
+	return getProduct(r)
+}
+
+// Add the product to the request context
+func withProduct(r *http.Request, product *ProductType) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), productContextKey, product))
+}
+
+// Handle requests in the context of the product
+func productHandler(product *ProductType, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, withProduct(r, product))
+	}
+}
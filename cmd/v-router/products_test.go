@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProducts(t *testing.T) {
+	restoreConfig(t)
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.DefaultChannel = "stable"
+	GlobalConfig.DefaultGroup = "v1"
+	GlobalConfig.PathTpls = "/includes"
+	GlobalConfig.PathProductsFile = filepath.Join(t.TempDir(), "products.yaml")
+
+	content := `products:
+ - name: docs
+   locationVersions: /documentation
+ - name: werf
+   locationVersions: /werf/documentation
+   defaultChannel: ea
+ - name: cli
+   locationVersions: /documentation/cli
+`
+	if err := ioutil.WriteFile(GlobalConfig.PathProductsFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadProducts(); err != nil {
+		t.Fatal(err)
+	}
+
+	werf := getProductByName("werf")
+	if werf == nil || werf.DefaultChannel != "ea" || werf.DefaultGroup != "v1" || werf.PathTpls != "/includes" {
+		t.Fatalf("unexpected werf product: %+v", werf)
+	}
+
+	for path, expected := range map[string]string{
+		"/documentation/v1/":         "docs",
+		"/en/documentation":          "docs",
+		"/ru/werf/documentation/v1/": "werf",
+		"/werf/documentation":        "werf",
+		// The nested location is checked before the location of the first product
+		"/en/documentation/cli/v1/": "cli",
+		"/documentation/cli":        "cli",
+		"/documentation/client/":    "docs",
+	} {
+		product := getProductByPath(path)
+		if product == nil || product.Name != expected {
+			t.Errorf("product for %s: expected %s, got %+v", path, expected, product)
+		}
+	}
+	if product := getDefaultProduct(); product.Name != "docs" {
+		t.Errorf("the first declared product must be the default one, got %s", product.Name)
+	}
+	if product := getProductByPath("/documentation-old/"); product != nil {
+		t.Errorf("expected no product for /documentation-old/, got %s", product.Name)
+	}
+
+	if err := ioutil.WriteFile(GlobalConfig.PathProductsFile, []byte("products:\n - name: docs\n   locationVersions: /documentation\n - name: docs\n   locationVersions: /docs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadProducts(); err == nil {
+		t.Error("expected an error for duplicate products")
+	}
+}
+
+func TestProductRouting(t *testing.T) {
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	docs := newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.0"}}}}})
+	werf := &ProductType{Name: "werf", LocationVersions: "/werf/documentation", DefaultChannel: "stable", PathTpls: "/includes"}
+	werf.releases.Store(&ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.2.5"}}}}})
+	Products = []*ProductType{werf, docs}
+
+	router := newRouter()
+	for path, expected := range map[string]string{
+		"/en/documentation/v1/":      "/en/documentation/v1.1.0/",
+		"/en/werf/documentation/v1/": "/en/werf/documentation/v1.2.5/",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("x-original-uri", path)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if actual := recorder.Header().Get("X-Accel-Redirect"); actual != expected {
+			t.Errorf("%s: expected X-Accel-Redirect to %s, got %s", path, expected, actual)
+		}
+	}
+}
+
+func TestProductRegexps(t *testing.T) {
+	product := newTestProduct(t, &ReleasesStatusType{})
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.Languages = []string{"en", "ru"}
+
+	newRouter()
+	if !product.regexps.langPath.MatchString("/ru/documentation/v1/") || product.regexps.langPath.MatchString("/de/documentation/v1/") {
+		t.Error("unexpected match of the product path with the en and ru languages")
+	}
+	if getProductByPath("/ru/documentation/v1/") != product {
+		t.Error("the product is not found by the path")
+	}
+
+	GlobalConfig.Languages = []string{"en", "de"}
+	newRouter()
+	if !product.regexps.langPath.MatchString("/de/documentation/v1/") {
+		t.Error("the regexps must be compiled for the languages of the router")
+	}
+}
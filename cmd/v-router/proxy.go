@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Data for the VROUTER_PROXY_UPSTREAM template
+type proxyUpstreamData struct {
+	Product    string
+	Version    string // E.g. v1.2.3+fix5
+	VersionURL string // E.g. v1.2.3-plus-fix5
+}
+
+// Transport shared by all proxied requests and the upstream URL template, see initProxy()
+var (
+	proxyTransport   http.RoundTripper
+	proxyUpstreamTpl *template.Template
+)
+
+// Create the proxy transport and parse the upstream URL template (VROUTER_PROXY_UPSTREAM)
+func initProxy() error {
+	proxyTransport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: GlobalConfig.ProxyTimeout,
+	}
+
+	proxyUpstreamTpl = nil
+	if GlobalConfig.ProxyUpstream == "" {
+		return nil
+	}
+	tpl, err := template.New("upstream").Option("missingkey=error").Parse(GlobalConfig.ProxyUpstream)
+	if err != nil {
+		return fmt.Errorf("can't parse the upstream template (%s)", err.Error())
+	}
+	proxyUpstreamTpl = tpl
+	return nil
+}
+
+// How long the server writes a response if versions are not proxied
+const serverWriteTimeout = 15 * time.Second
+
+// Get the server write timeout. Proxied responses are streamed without a time limit,
+// so there is no timeout if VROUTER_PROXY_UPSTREAM is set or the channels have upstreams at startup.
+func getServerWriteTimeout() time.Duration {
+	if GlobalConfig.ProxyUpstream != "" {
+		return 0
+	}
+	for _, product := range Products {
+		for _, group := range product.getReleasesStatus().Groups {
+			for _, channel := range group.Channels {
+				if channel.Upstream != "" {
+					return 0
+				}
+			}
+		}
+	}
+	return serverWriteTimeout
+}
+
+// Get the upstream URL serving the version, e.g. http://docs-v1-2-3.docs.svc:8080.
+// The upstream of the channel in the channels file takes precedence over VROUTER_PROXY_UPSTREAM.
+// Returns nil if the version is not proxied.
+func getVersionUpstream(product *ProductType, releases *ReleasesStatusType, version string) (*url.URL, error) {
+	var upstream string
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			if channel.Upstream != "" && channel.Version == version {
+				upstream = channel.Upstream
+			}
+		}
+	}
+
+	if upstream == "" && proxyUpstreamTpl != nil {
+		var buf bytes.Buffer
+		if err := proxyUpstreamTpl.Execute(&buf, proxyUpstreamData{Product: product.Name, Version: version, VersionURL: VersionToURL(version)}); err != nil {
+			return nil, fmt.Errorf("can't render the upstream template (%s)", err.Error())
+		}
+		upstream = buf.String()
+	}
+	if upstream == "" {
+		return nil, nil
+	}
+
+	result, err := url.Parse(upstream)
+	if err != nil || result.Scheme == "" || result.Host == "" {
+		return nil, fmt.Errorf("bad upstream URL %q for version %s", upstream, version)
+	}
+	return result, nil
+}
+
+// Proxy the request to the upstream. The upstream gets the specified path (e.g. /en/documentation/v1.2.3/cli/)
+// and the query of the original request. VROUTER_PROXY_TIMEOUT limits the time to wait for the response headers,
+// the response body is streamed without a time limit.
+func proxyToUpstream(w http.ResponseWriter, r *http.Request, upstream *url.URL, path string) {
+	log.Debugln(fmt.Sprintf("Proxy %s to %s%s", r.URL.RequestURI(), upstream.String(), path))
+
+	originalHost := r.Host
+	originalURI := r.URL.RequestURI()
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = upstream.Scheme
+			req.URL.Host = upstream.Host
+			req.URL.Path = strings.TrimSuffix(upstream.Path, "/") + path
+			req.URL.RawPath = ""
+			req.Host = upstream.Host
+			if req.Header.Get("X-Forwarded-Host") == "" {
+				req.Header.Set("X-Forwarded-Host", originalHost)
+			}
+			if req.Header.Get("X-Forwarded-Proto") == "" {
+				req.Header.Set("X-Forwarded-Proto", proto)
+			}
+			req.Header.Set("X-Original-URI", originalURI)
+			// Don't let the upstream use the Go user agent
+			if _, ok := req.Header["User-Agent"]; !ok {
+				req.Header.Set("User-Agent", "")
+			}
+		},
+		Transport: proxyTransport,
+		// Stream responses to the client as soon as data arrive
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			// Redirects to the upstream host are rewritten to the host of the router
+			if location, err := resp.Location(); err == nil && location.Host == upstream.Host {
+				location.Scheme, location.Host = "", ""
+				location.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(location.Path, strings.TrimSuffix(upstream.Path, "/")), "/")
+				resp.Header.Set("Location", location.String())
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				status = http.StatusGatewayTimeout
+			}
+			if errors.Is(err, context.Canceled) {
+				// The client has gone, nothing to respond
+				log.Debugln(fmt.Sprintf("Proxy request to %s was canceled", upstream.Host))
+				return
+			}
+			log.Errorf("Upstream %s error for %s: %s", upstream.Host, originalURI, err.Error())
+			http.Error(w, http.StatusText(status), status)
+		},
+	}
+
+	proxy.ServeHTTP(w, r)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyMode(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/documentation/v1.1.5/slow/":
+			time.Sleep(200 * time.Millisecond)
+		case "/documentation/v1.1.5/moved/":
+			http.Redirect(w, r, "http://"+r.Host+"/documentation/v1.1.5/new/", 301)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(r.Host + " " + r.URL.RequestURI() + " " + r.Header.Get("X-Forwarded-Host") + " " + r.Header.Get("X-Original-URI")))
+	}))
+	defer upstream.Close()
+	channelUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("channel upstream " + r.URL.Path))
+	}))
+	defer channelUpstream.Close()
+
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.ProxyUpstream = strings.Replace(upstream.URL, "127.0.0.1", "{{ if eq .VersionURL \"v1.1.5\" }}127.0.0.1{{ else }}localhost{{ end }}", 1)
+	GlobalConfig.ProxyTimeout = 100 * time.Millisecond
+	if err := initProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.2.0", Upstream: channelUpstream.URL}}},
+	}})
+	router := newRouter()
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Host = "docs.example.com"
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+	// The group is proxied instead of X-Accel-Redirect
+	recorder := serve("/documentation/v1/cli/?q=1")
+	if body := recorder.Body.String(); recorder.Code != http.StatusOK || body != upstreamHost+" /documentation/v1.1.5/cli/?q=1 docs.example.com /documentation/v1/cli/?q=1" {
+		t.Errorf("Wrong proxied group response: %d %s", recorder.Code, body)
+	}
+	if recorder.Header().Get("X-Accel-Redirect") != "" {
+		t.Errorf("X-Accel-Redirect must not be set in the proxy mode")
+	}
+
+	// Specific versions are proxied, the upstream of the channel takes precedence over the template
+	if body := serve("/documentation/v1.2.0/index.html").Body.String(); body != "channel upstream /documentation/v1.2.0/index.html" {
+		t.Errorf("Wrong proxied version response: %s", body)
+	}
+
+	// Redirects to the upstream are rewritten
+	if location := serve("/documentation/v1.1.5/moved/").Header().Get("Location"); location != "/documentation/v1.1.5/new/" {
+		t.Errorf("Wrong rewritten redirect: %s", location)
+	}
+
+	// Timeouts
+	if code := serve("/documentation/v1.1.5/slow/").Code; code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 for a slow upstream, got %d", code)
+	}
+}
+
+func TestProxyUpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstreamURL := upstream.URL
+	upstream.Close()
+
+	GlobalConfig.ProxyUpstream = upstreamURL
+	defer func() {
+		GlobalConfig.ProxyUpstream = ""
+		_ = initProxy()
+	}()
+	if err := initProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	proxyVersion(recorder, httptest.NewRequest("GET", "/documentation/v1.1.5/", nil), &ProductType{Name: "docs"}, &ReleasesStatusType{}, "v1.1.5", "/documentation/v1.1.5/")
+	if recorder.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for an unavailable upstream, got %d", recorder.Code)
+	}
+	if body, _ := ioutil.ReadAll(recorder.Body); len(body) == 0 {
+		t.Errorf("Empty error response")
+	}
+}
+
+func TestServerWriteTimeout(t *testing.T) {
+	product := newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}}}}})
+	if timeout := getServerWriteTimeout(); timeout != serverWriteTimeout {
+		t.Errorf("Expected the %s write timeout without the proxy mode, got %s", serverWriteTimeout, timeout)
+	}
+
+	// Proxied responses are not limited
+	product.releases.Store(&ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5", Upstream: "http://docs-v1:8080"}}}}})
+	if timeout := getServerWriteTimeout(); timeout != 0 {
+		t.Errorf("Expected no write timeout with channel upstreams, got %s", timeout)
+	}
+}
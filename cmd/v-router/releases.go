@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type releasesLoadStateType struct {
+	LoadTime      time.Time            // When the currently served data was loaded
+	LastError     string               // Error of the last load attempt, empty if it succeeded
+	LastErrorTime time.Time            //
+	Sources       []channelSourceState // Load state of every configured source
+}
+
+// Get the current snapshot of the product channels data.
+// The result is shared between requests and must not be modified.
+func (p *ProductType) getReleasesStatus() *ReleasesStatusType {
+	if releases, ok := p.releases.Load().(*ReleasesStatusType); ok {
+		return releases
+	}
+	return &ReleasesStatusType{}
+}
+
+// Get the result of the last channels data load
+func (p *ProductType) getReleasesLoadState() *releasesLoadStateType {
+	if state, ok := p.loadState.Load().(*releasesLoadStateType); ok {
+		return state
+	}
+	return &releasesLoadStateType{}
+}
+
+// Get the format of the channels data ("json" or "yaml") by the file name or the content type
+func getChannelsFormat(name, contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		return "json"
+	case strings.Contains(contentType, "yaml"):
+		return "yaml"
+	case strings.HasSuffix(name, ".json"):
+		return "json"
+	case strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml"):
+		return "yaml"
+	}
+	return ""
+}
+
+// Decode the channels data in the specified format and check it
+func decodeReleasesStatus(data []byte, format, name string) (*ReleasesStatusType, error) {
+	var err error
+	releases := &ReleasesStatusType{}
+
+	switch format {
+	case "json":
+		err = unmarshalJSON(data, releases)
+	case "yaml":
+		err = unmarshalYAML(data, releases)
+	default:
+		return nil, fmt.Errorf("failed to decode channels file %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't decode channels file %s (%s)", name, err.Error())
+	}
+	if err = checkReleasesStatus(releases, name); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// Check that the decoded channels data is usable.
+// A half-written file can often be decoded without errors, but with missing data.
+func checkReleasesStatus(releases *ReleasesStatusType, name string) error {
+	if len(releases.Groups) == 0 && len(releases.Channels) == 0 {
+		return fmt.Errorf("no groups found in %s", name)
+	}
+	for _, group := range releases.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("group without a name found in %s", name)
+		}
+		for _, channel := range group.Channels {
+			if channel.Name == "" || channel.Version == "" {
+				return fmt.Errorf("channel without a name or a version found in the %s group (%s)", group.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Load the product channels data and atomically replace the current snapshot.
+// If the data can't be loaded, the last valid data is kept and the error is saved to the load state.
+func (p *ProductType) updateReleasesStatus() error {
+	if p.source == nil {
+		p.source = p.newChannelSource()
+	}
+
+	state := *p.getReleasesLoadState()
+	releases, changed, err := p.source.Load()
+	if releases != nil && changed {
+		p.releases.Store(releases)
+		state.LoadTime = time.Now()
+	}
+
+	if err != nil {
+		state.LastError = err.Error()
+		state.LastErrorTime = time.Now()
+	} else {
+		state.LastError = ""
+	}
+	if source, ok := p.source.(*mergedChannelSource); ok {
+		state.Sources = source.States()
+	}
+	p.loadState.Store(&state)
+	return err
+}
+
+// Load channels data of all the products
+func updateReleasesStatus() {
+	for _, product := range Products {
+		if err := product.updateReleasesStatus(); err != nil {
+			log.Errorln(fmt.Sprintf("Can't load channels data of the %s product: %s", product.Name, err.Error()))
+		}
+	}
+}
+
+// Periodically reload channels data of all the products. Sources load the data only if it has changed.
+func watchReleasesStatus(interval time.Duration) {
+	if interval <= 0 {
+		log.Infoln("Channels file reloading is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, product := range Products {
+			lastError := product.getReleasesLoadState().LastError
+			if err := product.updateReleasesStatus(); err != nil {
+				// Don't repeat the same error on every check
+				if err.Error() != lastError {
+					log.Errorf("Can't reload channels data of the %s product, the last valid data is used (%s)", product.Name, err.Error())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateReleasesStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channels.yaml")
+	if err := ioutil.WriteFile(path, []byte("groups:\n - name: v1\n   channels:\n    - name: stable\n      version: v1.1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &fileChannelSource{path: path}
+	product := &ProductType{Name: "default", source: source}
+	if err := product.updateReleasesStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := product.getReleasesStatus()
+	if version, _ := getVersionFromChannelAndGroup(snapshot, "stable", "v1"); version != "v1.1.0" {
+		t.Errorf("Wrong version in the loaded data, expected v1.1.0, got %s", version)
+	}
+
+	if _, changed, _ := source.Load(); changed {
+		t.Errorf("Unchanged file reported as changed")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("groups:\n - name: v1\n   channels:\n    - name: stable\n      version: v1.2.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source.modTime = source.modTime.Add(-time.Second)
+	if err := product.updateReleasesStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, _ := getVersionFromChannelAndGroup(product.getReleasesStatus(), "stable", "v1"); version != "v1.2.0" {
+		t.Errorf("Wrong version after reload, expected v1.2.0, got %s", version)
+	}
+	if version, _ := getVersionFromChannelAndGroup(snapshot, "stable", "v1"); version != "v1.1.0" {
+		t.Errorf("Previous snapshot has been modified, expected v1.1.0, got %s", version)
+	}
+}
+
+func TestUpdateReleasesStatusKeepsLastValidData(t *testing.T) {
+	product := newTestProduct(t, &ReleasesStatusType{})
+	product.PathChannelsFile = filepath.Join(t.TempDir(), "channels.yaml")
+	writeChannelsFile := func(content string) {
+		if err := ioutil.WriteFile(product.PathChannelsFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeChannelsFile("groups:\n - name: v1\n   channels:\n    - name: stable\n      version: v1.1.0\n")
+	if err := product.updateReleasesStatus(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"groups:\n - name: v1\n   channels:\n    - name: [", "groups:\n", ""} {
+		writeChannelsFile(content)
+		if err := product.updateReleasesStatus(); err == nil {
+			t.Errorf("Broken channels file %q loaded without an error", content)
+		}
+		if version, _ := getVersionFromChannelAndGroup(product.getReleasesStatus(), "stable", "v1"); version != "v1.1.0" {
+			t.Errorf("The last valid data is not kept, expected v1.1.0, got %s", version)
+		}
+		if state := product.getReleasesLoadState(); state.LastError == "" || state.LastErrorTime.IsZero() {
+			t.Errorf("Load error is not saved: %+v", state)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	statusHandler(recorder, httptest.NewRequest("GET", "/status", nil))
+	var response APIStatusResponseType
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != "error" || response.LastError == "" || response.LastErrorTime == nil || len(response.Releases) != 1 {
+		t.Errorf("Wrong status response: %s", recorder.Body.String())
+	}
+
+	writeChannelsFile("groups:\n - name: v1\n   channels:\n    - name: stable\n      version: v1.2.0\n")
+	if err := product.updateReleasesStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if state := product.getReleasesLoadState(); state.LastError != "" {
+		t.Errorf("Load error is not cleared after recovery: %s", state.LastError)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	channelsFetchTimeout = 10 * time.Second
+	channelsMaxBackoff   = 5 * time.Minute
+)
+
+// Channels file fetched by an HTTP(S) URL using conditional requests.
+// On errors, the delay between requests is doubled up to channelsMaxBackoff.
+type urlChannelSource struct {
+	url          string
+	interval     time.Duration
+	client       *http.Client
+	etag         string
+	lastModified string
+	backoff      time.Duration
+	retryTime    time.Time
+	lastError    error
+}
+
+// Checks if the channels file path is an HTTP(S) URL
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+func newURLChannelSource(url string, interval time.Duration) *urlChannelSource {
+	return &urlChannelSource{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: channelsFetchTimeout},
+	}
+}
+
+func (s *urlChannelSource) Name() string {
+	return s.url
+}
+
+func (s *urlChannelSource) Load() (*ReleasesStatusType, bool, error) {
+	if time.Now().Before(s.retryTime) {
+		return nil, false, s.lastError
+	}
+
+	releases, changed, err := s.fetch()
+	if err != nil {
+		// Download the file again next time instead of getting "not modified" for the broken content
+		s.etag = ""
+		s.lastModified = ""
+		s.backoff = nextBackoffDelay(s.backoff, s.interval)
+		s.retryTime = time.Now().Add(s.backoff)
+		s.lastError = fmt.Errorf("%s, next try in %s", err.Error(), s.backoff)
+		return nil, false, s.lastError
+	}
+
+	s.backoff = 0
+	s.retryTime = time.Time{}
+	s.lastError = nil
+	return releases, changed, nil
+}
+
+// Fetch and decode the channels file.
+// Returns changed == false if the server responded that the file hasn't been modified since the previous fetch.
+func (s *urlChannelSource) fetch() (*ReleasesStatusType, bool, error) {
+	req, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, false, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("can't fetch channels file %s: unexpected response status %s", s.url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	name := s.url
+	if u, err := url.Parse(s.url); err == nil {
+		name = u.Path
+	}
+	format := getChannelsFormat(name, resp.Header.Get("Content-Type"))
+	if format == "" {
+		// YAML is a superset of JSON, so it can decode documents of an unknown type
+		format = "yaml"
+	}
+
+	releases, err := decodeReleasesStatus(data, format, s.url)
+	if err != nil {
+		return nil, false, err
+	}
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	return releases, true, nil
+}
+
+// Get the next delay for a request retry
+func nextBackoffDelay(delay, interval time.Duration) time.Duration {
+	maxDelay := channelsMaxBackoff
+	if maxDelay < interval {
+		maxDelay = interval
+	}
+	if delay < interval {
+		delay = interval
+	}
+	delay *= 2
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchReleasesStatus(t *testing.T) {
+	var requests []*http.Request
+	responseStatus := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if responseStatus != http.StatusOK {
+			w.WriteHeader(responseStatus)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"groups": [{"name": "v1", "channels": [{"name": "stable", "version": "v1.1.0"}]}]}`))
+	}))
+	defer server.Close()
+
+	source := newURLChannelSource(server.URL+"/channels", time.Minute)
+	product := &ProductType{Name: "default", source: source}
+
+	if err := product.updateReleasesStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := getVersionFromChannelAndGroup(product.getReleasesStatus(), "stable", "v1"); version != "v1.1.0" {
+		t.Errorf("Wrong version in the fetched data, expected v1.1.0, got %s", version)
+	}
+
+	if _, changed, err := source.Load(); err != nil || changed {
+		t.Errorf("Unchanged file is reported as changed (err - %v)", err)
+	}
+	if len(requests) != 2 || requests[1].Header.Get("If-None-Match") != `"1"` {
+		t.Errorf("Conditional request is not used")
+	}
+
+	responseStatus = http.StatusInternalServerError
+	if err := product.updateReleasesStatus(); err == nil {
+		t.Errorf("Server error is not reported")
+	}
+	if version, _ := getVersionFromChannelAndGroup(product.getReleasesStatus(), "stable", "v1"); version != "v1.1.0" {
+		t.Errorf("The last valid data is not kept, expected v1.1.0, got %s", version)
+	}
+
+	// The source backs off after the error
+	if _, _, err := source.Load(); err == nil || len(requests) != 3 {
+		t.Errorf("Request is sent during the backoff delay")
+	}
+	if source.backoff != 2*time.Minute {
+		t.Errorf("Wrong backoff delay, expected %s, got %s", 2*time.Minute, source.backoff)
+	}
+}
+
+func TestNextBackoffDelay(t *testing.T) {
+	if delay := nextBackoffDelay(channelsFetchTimeout, channelsFetchTimeout); delay != 2*channelsFetchTimeout {
+		t.Errorf("Wrong delay, expected %s, got %s", 2*channelsFetchTimeout, delay)
+	}
+	if delay := nextBackoffDelay(channelsMaxBackoff, channelsFetchTimeout); delay != channelsMaxBackoff {
+		t.Errorf("Delay is not limited, expected %s, got %s", channelsMaxBackoff, delay)
+	}
+}
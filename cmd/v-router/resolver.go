@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Result of choosing the version of a group, when the channel is not specified in the URL (e.g. /documentation/v1.2/)
+type versionResolutionType struct {
+	Group   string `json:"group"`
+	Channel string `json:"channel,omitempty"`
+	Version string `json:"version,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Get channels to search a version in, starting from the specified channel.
+// The order is taken from the fallbackChannels field of the group or from VROUTER_FALLBACK_CHANNELS.
+// If it isn't set, less stable channels are checked first, then more stable ones, starting from the nearest.
+// E.g. "stable", "ea", "beta", "alpha", "rock-solid" for the "stable" channel.
+func getFallbackOrder(releases *ReleasesStatusType, group *ReleaseType, channel string) (order []string, source string) {
+	var fallback []string
+	switch {
+	case group != nil && len(group.FallbackChannels) > 0:
+		fallback, source = group.FallbackChannels, fmt.Sprintf("the fallback order of the %s group", group.Name)
+	case len(GlobalConfig.FallbackChannels) > 0:
+		fallback, source = GlobalConfig.FallbackChannels, "the VROUTER_FALLBACK_CHANNELS fallback order"
+	default:
+		source = "the default fallback order"
+		channels := getChannelsListReverseStability(releases)
+		index := -1
+		for i, item := range channels {
+			if item == channel {
+				index = i
+			}
+		}
+		if index < 0 {
+			fallback = channels
+		} else {
+			fallback = append(fallback, channels[index+1:]...)
+			for i := index - 1; i >= 0; i-- {
+				fallback = append(fallback, channels[i])
+			}
+		}
+	}
+
+	order = []string{channel}
+	for _, item := range fallback {
+		if !contains(order, item) {
+			order = append(order, item)
+		}
+	}
+	return
+}
+
+// Choose the version of the group: the version of the default channel, or the version of the first channel
+// of the fallback order present in the group. The result explains which channel was chosen and why.
+// The default channel of the group takes precedence over the default channel of the product.
+func resolveGroupVersion(product *ProductType, releases *ReleasesStatusType, groupName string) (result versionResolutionType, err error) {
+	result.Group = groupName
+	defaultChannel, defaultSource := product.DefaultChannel, "the default channel"
+
+	group := getReleaseGroup(releases, groupName)
+	if group != nil && group.DefaultChannel != "" {
+		defaultChannel, defaultSource = group.DefaultChannel, "the default channel of the group"
+	}
+
+	if defaultChannel == "latest" {
+		result.Channel, result.Version = "latest", "latest"
+		result.Reason = fmt.Sprintf("%s is latest", defaultSource)
+		return result, nil
+	}
+
+	if group == nil {
+		result.Reason = fmt.Sprintf("the %s group is not found", groupName)
+		return result, fmt.Errorf("can't get version for group %s: %s", groupName, result.Reason)
+	}
+
+	order, source := getFallbackOrder(releases, group, defaultChannel)
+	for i, channel := range order {
+		for _, item := range group.Channels {
+			if item.Name != channel {
+				continue
+			}
+			result.Channel, result.Version = item.Name, item.Version
+			if i == 0 {
+				result.Reason = fmt.Sprintf("%s is %s", channel, defaultSource)
+			} else {
+				result.Reason = fmt.Sprintf("%s %s is absent, %s is the first channel present in the group according to %s (%s)", defaultSource, defaultChannel, channel, source, strings.Join(order, ", "))
+			}
+			return result, nil
+		}
+	}
+
+	result.Reason = fmt.Sprintf("none of the channels of %s (%s) is present in the group", source, strings.Join(order, ", "))
+	return result, fmt.Errorf("can't get version for group %s: %s", groupName, result.Reason)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetFallbackOrder(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.FallbackChannels = nil
+	defer func() { GlobalConfig.FallbackChannels = nil }()
+
+	tests := []struct {
+		channel  string
+		group    *ReleaseType
+		global   []string
+		expected []string
+	}{
+		{"stable", nil, nil, []string{"stable", "ea", "beta", "alpha", "rock-solid"}},
+		{"rock-solid", nil, nil, []string{"rock-solid", "stable", "ea", "beta", "alpha"}},
+		{"beta", nil, nil, []string{"beta", "alpha", "ea", "stable", "rock-solid"}},
+		{"stable", nil, []string{"rock-solid", "ea"}, []string{"stable", "rock-solid", "ea"}},
+		{"stable", &ReleaseType{Name: "v1", FallbackChannels: []string{"alpha", "stable"}}, []string{"rock-solid"}, []string{"stable", "alpha"}},
+	}
+	for _, test := range tests {
+		GlobalConfig.FallbackChannels = test.global
+		if order, _ := getFallbackOrder(nil, test.group, test.channel); !reflect.DeepEqual(order, test.expected) {
+			t.Errorf("Wrong fallback order for %s (global %v): got %v, want %v", test.channel, test.global, order, test.expected)
+		}
+	}
+}
+
+func TestResolveGroupVersion(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.FallbackChannels = nil
+
+	releases := &ReleasesStatusType{
+		Groups: []ReleaseType{
+			{Name: "v1.1", Channels: []ChannelType{{Name: "rock-solid", Version: "v1.1.3"}, {Name: "stable", Version: "v1.1.5"}}},
+			{Name: "v1.2", Channels: []ChannelType{{Name: "rock-solid", Version: "v1.2.1"}, {Name: "alpha", Version: "v1.2.7"}}},
+			{Name: "v1.3", Channels: []ChannelType{{Name: "rock-solid", Version: "v1.3.0"}, {Name: "alpha", Version: "v1.3.2"}}, FallbackChannels: []string{"rock-solid"}},
+			{Name: "v2.0", Channels: []ChannelType{{Name: "stable", Version: "v2.0.1"}, {Name: "ea", Version: "v2.0.3"}}, DefaultChannel: "ea"},
+		},
+	}
+
+	tests := []struct {
+		defaultChannel, group, version, channel string
+	}{
+		{"stable", "v1.1", "v1.1.5", "stable"},
+		{"rock-solid", "v1.1", "v1.1.3", "rock-solid"},
+		{"stable", "v1.2", "v1.2.7", "alpha"},
+		{"stable", "v1.3", "v1.3.0", "rock-solid"},
+		{"latest", "v1.3", "latest", "latest"},
+		{"stable", "v2.0", "v2.0.3", "ea"},
+		{"latest", "v2.0", "v2.0.3", "ea"},
+	}
+	for _, test := range tests {
+		product := &ProductType{DefaultChannel: test.defaultChannel}
+		resolution, err := resolveGroupVersion(product, releases, test.group)
+		if err != nil {
+			t.Errorf("Can't resolve %s with the %s default channel: %s", test.group, test.defaultChannel, err.Error())
+			continue
+		}
+		if resolution.Version != test.version || resolution.Channel != test.channel || resolution.Reason == "" {
+			t.Errorf("Wrong resolution for %s with the %s default channel: %+v", test.group, test.defaultChannel, resolution)
+		}
+	}
+
+	resolution, _ := resolveGroupVersion(&ProductType{DefaultChannel: "stable"}, releases, "v1.3")
+	if !strings.Contains(resolution.Reason, "v1.3 group") {
+		t.Errorf("The group fallback order is not mentioned in the reason: %s", resolution.Reason)
+	}
+
+	if _, err := resolveGroupVersion(&ProductType{DefaultChannel: "stable"}, releases, "v2"); err == nil {
+		t.Error("Expected an error for an unknown group")
+	}
+	if _, err := resolveGroupVersion(&ProductType{DefaultChannel: "ea"}, &ReleasesStatusType{Groups: []ReleaseType{{Name: "v1", FallbackChannels: []string{"beta"}, Channels: []ChannelType{{Name: "alpha", Version: "v1.0.1"}}}}}, "v1"); err == nil {
+		t.Error("Expected an error if no channels of the fallback order are present")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Source of the channels data, e.g. a file, a directory or a URL
+type ChannelSource interface {
+	// Source name for logs and the status, e.g. a file path
+	Name() string
+	// Load the channels data. Returns changed == false if the data hasn't changed since the previous successful load.
+	// A source combining other sources may return data together with an error if some of them failed to load.
+	Load() (releases *ReleasesStatusType, changed bool, err error)
+}
+
+// Load state of a channels source
+type channelSourceState struct {
+	Name          string     `json:"name"`
+	LoadTime      *time.Time `json:"loadTime,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// Create the channels source of the product according to its configuration
+func (p *ProductType) newChannelSource() ChannelSource {
+	var sources []ChannelSource
+
+	if path := p.PathChannelsFile; path != "" {
+		if isURL(path) {
+			sources = append(sources, newURLChannelSource(path, GlobalConfig.ChannelsReloadInterval))
+		} else if fileInfo, err := os.Stat(path); err == nil && fileInfo.IsDir() {
+			sources = append(sources, newDirChannelSource(path))
+		} else {
+			sources = append(sources, &fileChannelSource{path: path})
+		}
+	}
+	if p.ChannelsData != "" {
+		sources = append(sources, &inlineChannelSource{data: p.ChannelsData})
+	}
+
+	return newMergedChannelSource("channels", sources)
+}
+
+// Merge channels data of several sources into the result.
+// Channels of groups with the same name are combined, a channel of the later source overrides the channel of the earlier one.
+// Other group fields (e.g. the end-of-life date) are taken from the first source defining the group.
+func mergeReleasesStatus(result, releases *ReleasesStatusType) {
+	if len(result.Channels) == 0 {
+		result.Channels = releases.Channels
+	}
+
+	// An alias is taken from the first source defining it
+	for alias, channel := range releases.Aliases {
+		if result.Aliases == nil {
+			result.Aliases = make(map[string]string)
+		}
+		if _, ok := result.Aliases[alias]; !ok {
+			result.Aliases[alias] = channel
+		}
+	}
+
+	for _, group := range releases.Groups {
+		index := -1
+		for i := range result.Groups {
+			if result.Groups[i].Name == group.Name {
+				index = i
+			}
+		}
+		if index < 0 {
+			newGroup := group
+			newGroup.Channels = nil
+			result.Groups = append(result.Groups, newGroup)
+			index = len(result.Groups) - 1
+		}
+
+		// Copy channels, so the data of the source is not modified
+		channels := append([]ChannelType{}, result.Groups[index].Channels...)
+		for _, channel := range group.Channels {
+			replaced := false
+			for i := range channels {
+				if channels[i].Name == channel.Name {
+					channels[i] = channel
+					replaced = true
+				}
+			}
+			if !replaced {
+				channels = append(channels, channel)
+			}
+		}
+		result.Groups[index].Channels = channels
+	}
+}
+
+// A single channels file. The file is reloaded when its modification time or size changes.
+type fileChannelSource struct {
+	path    string
+	loaded  bool
+	modTime time.Time
+	size    int64
+}
+
+func (s *fileChannelSource) Name() string {
+	return s.path
+}
+
+func (s *fileChannelSource) Load() (*ReleasesStatusType, bool, error) {
+	fileInfo, err := os.Stat(s.path)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.loaded && fileInfo.ModTime().Equal(s.modTime) && fileInfo.Size() == s.size {
+		return nil, false, nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		log.Errorf("Can't open %s (%s)", s.path, err.Error())
+		return nil, false, err
+	}
+	releases, err := decodeReleasesStatus(data, getChannelsFormat(s.path, ""), s.path)
+	if err != nil {
+		// The file state is not saved, so the file is read again on the next load
+		return nil, false, err
+	}
+
+	s.loaded = true
+	s.modTime = fileInfo.ModTime()
+	s.size = fileInfo.Size()
+	return releases, true, nil
+}
+
+// Channels data from the VROUTER_CHANNELS_DATA environment variable (YAML or JSON)
+type inlineChannelSource struct {
+	data   string
+	loaded bool
+}
+
+func (s *inlineChannelSource) Name() string {
+	return "VROUTER_CHANNELS_DATA"
+}
+
+func (s *inlineChannelSource) Load() (*ReleasesStatusType, bool, error) {
+	if s.loaded {
+		return nil, false, nil
+	}
+	// YAML is a superset of JSON, so both formats are decoded
+	releases, err := decodeReleasesStatus([]byte(s.data), "yaml", s.Name())
+	if err != nil {
+		return nil, false, err
+	}
+	s.loaded = true
+	return releases, true, nil
+}
+
+// Combines several sources. If a source fails to load, its last valid data is used.
+type mergedChannelSource struct {
+	name    string
+	sources []ChannelSource
+	data    map[string]*ReleasesStatusType // The last valid data by source name
+	states  map[string]*channelSourceState
+	removed bool // Whether a source has been removed since the previous load
+}
+
+func newMergedChannelSource(name string, sources []ChannelSource) *mergedChannelSource {
+	return &mergedChannelSource{
+		name:    name,
+		sources: sources,
+		data:    make(map[string]*ReleasesStatusType),
+		states:  make(map[string]*channelSourceState),
+	}
+}
+
+func (s *mergedChannelSource) Name() string {
+	return s.name
+}
+
+// Replace the list of sources. Data of removed sources is dropped on the next load.
+func (s *mergedChannelSource) setSources(sources []ChannelSource) {
+	names := make(map[string]bool)
+	for _, source := range sources {
+		names[source.Name()] = true
+	}
+	for name := range s.states {
+		if !names[name] {
+			delete(s.data, name)
+			delete(s.states, name)
+			s.removed = true
+		}
+	}
+	s.sources = sources
+}
+
+func (s *mergedChannelSource) Load() (*ReleasesStatusType, bool, error) {
+	var errs []string
+	changed := s.removed
+	s.removed = false
+
+	for _, source := range s.sources {
+		name := source.Name()
+		state, ok := s.states[name]
+		if !ok {
+			state = &channelSourceState{Name: name}
+			s.states[name] = state
+		}
+
+		releases, sourceChanged, err := source.Load()
+		now := time.Now()
+		if releases != nil && sourceChanged {
+			s.data[name] = releases
+			state.LoadTime = &now
+			changed = true
+		}
+		if err != nil {
+			state.LastError = err.Error()
+			state.LastErrorTime = &now
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+		} else {
+			state.LastError = ""
+			state.LastErrorTime = nil
+		}
+	}
+
+	var err error
+	if len(errs) > 0 {
+		err = errors.New(strings.Join(errs, "; "))
+	}
+	if !changed {
+		return nil, false, err
+	}
+
+	result := &ReleasesStatusType{}
+	for _, source := range s.sources {
+		if releases, ok := s.data[source.Name()]; ok {
+			mergeReleasesStatus(result, releases)
+		}
+	}
+	return result, true, err
+}
+
+// Get load states of the sources
+func (s *mergedChannelSource) States() (result []channelSourceState) {
+	for _, source := range s.sources {
+		if state, ok := s.states[source.Name()]; ok {
+			result = append(result, *state)
+		}
+	}
+	return
+}
+
+// A directory with channels files, e.g. one file per group. Files are merged in the alphabetical order.
+type dirChannelSource struct {
+	path   string
+	merged *mergedChannelSource
+	files  map[string]*fileChannelSource
+}
+
+func newDirChannelSource(path string) *dirChannelSource {
+	return &dirChannelSource{
+		path:   path,
+		merged: newMergedChannelSource(path, nil),
+		files:  make(map[string]*fileChannelSource),
+	}
+}
+
+func (s *dirChannelSource) Name() string {
+	return s.path
+}
+
+func (s *dirChannelSource) Load() (*ReleasesStatusType, bool, error) {
+	items, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var sources []ChannelSource
+	files := make(map[string]*fileChannelSource)
+	for _, item := range items {
+		// Skip hidden files, e.g. the ..data directory of a Kubernetes ConfigMap volume
+		if item.IsDir() || strings.HasPrefix(item.Name(), ".") || getChannelsFormat(item.Name(), "") == "" {
+			continue
+		}
+		path := filepath.Join(s.path, item.Name())
+		file, ok := s.files[path]
+		if !ok {
+			file = &fileChannelSource{path: path}
+		}
+		files[path] = file
+		sources = append(sources, file)
+	}
+	s.files = files
+	s.merged.setSources(sources)
+
+	return s.merged.Load()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirChannelSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("v1.1.yaml", "groups:\n - name: v1.1\n   channels:\n    - name: stable\n      version: v1.1.5\n")
+	writeFile("v1.2.json", `{"groups": [{"name": "v1.2", "channels": [{"name": "alpha", "version": "v1.2.1"}]}]}`)
+	writeFile("README.txt", "not a channels file")
+
+	source := newMergedChannelSource("channels", []ChannelSource{
+		newDirChannelSource(dir),
+		&inlineChannelSource{data: "groups:\n - name: v1.2\n   channels:\n    - name: beta\n      version: v1.2.0\n"},
+	})
+
+	releases, changed, err := source.Load()
+	if err != nil || !changed {
+		t.Fatalf("Can't load channels data (changed - %v, err - %v)", changed, err)
+	}
+	for _, item := range [][3]string{{"v1.1", "stable", "v1.1.5"}, {"v1.2", "alpha", "v1.2.1"}, {"v1.2", "beta", "v1.2.0"}} {
+		if version, _ := getVersionFromChannelAndGroup(releases, item[1], item[0]); version != item[2] {
+			t.Errorf("Wrong version for %s-%s, expected %s, got %s", item[0], item[1], item[2], version)
+		}
+	}
+
+	if _, changed, _ := source.Load(); changed {
+		t.Errorf("Unchanged sources are reported as changed")
+	}
+
+	// A broken file keeps its last valid data, other files are loaded
+	writeFile("v1.2.json", `{"groups": [{"name": "v1.2", "channels": [`)
+	writeFile("v1.3.yaml", "groups:\n - name: v1.3\n   channels:\n    - name: alpha\n      version: v1.3.0\n")
+	releases, changed, err = source.Load()
+	if err == nil || !changed {
+		t.Fatalf("Broken file is not reported (changed - %v, err - %v)", changed, err)
+	}
+	if version, _ := getVersionFromChannelAndGroup(releases, "alpha", "v1.2"); version != "v1.2.1" {
+		t.Errorf("The last valid data of the broken file is not kept, got %s", version)
+	}
+	if version, _ := getVersionFromChannelAndGroup(releases, "alpha", "v1.3"); version != "v1.3.0" {
+		t.Errorf("New file is not loaded, got %s", version)
+	}
+	if states := source.States(); len(states) != 2 || states[0].LastError == "" || states[1].LastError != "" {
+		t.Errorf("Wrong source states: %+v", states)
+	}
+
+	// Data of a removed file is dropped
+	if err := os.Remove(filepath.Join(dir, "v1.2.json")); err != nil {
+		t.Fatal(err)
+	}
+	releases, changed, err = source.Load()
+	if err != nil || !changed {
+		t.Fatalf("Removed file is not handled (changed - %v, err - %v)", changed, err)
+	}
+	if _, err := getVersionFromChannelAndGroup(releases, "alpha", "v1.2"); err == nil {
+		t.Errorf("Data of the removed file is still used")
+	}
+}
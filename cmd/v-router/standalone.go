@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// Data for the VROUTER_VERSIONS_LAYOUT template
+type versionsLayoutData struct {
+	Product    string
+	Version    string // E.g. v1.2.3+fix5
+	VersionURL string // E.g. v1.2.3-plus-fix5
+	Lang       string
+}
+
+// The layout template of version directories, see initStandalone()
+var versionsLayoutTpl *template.Template
+
+// Parse the layout template of version directories (VROUTER_VERSIONS_LAYOUT)
+func initStandalone() error {
+	tpl, err := template.New("layout").Option("missingkey=error").Parse(GlobalConfig.VersionsLayout)
+	if err != nil {
+		versionsLayoutTpl = nil
+		return fmt.Errorf("can't parse the layout template (%s)", err.Error())
+	}
+	versionsLayoutTpl = tpl
+	return nil
+}
+
+// Get the directory with the files of the version in the static file system, e.g. v1.2.3-plus-fix5.
+// The directory can't be outside of the file system root.
+func getVersionFilesPath(product *ProductType, version, lang string) (string, error) {
+	if versionsLayoutTpl == nil {
+		return "", errors.New("the layout template is not parsed")
+	}
+	var buf bytes.Buffer
+	if err := versionsLayoutTpl.Execute(&buf, versionsLayoutData{Product: product.Name, Version: version, VersionURL: VersionToURL(version), Lang: lang}); err != nil {
+		return "", fmt.Errorf("can't render the layout template (%s)", err.Error())
+	}
+
+	dir := toFSPath(strings.TrimSpace(buf.String()))
+	if dir == "." {
+		return "", fmt.Errorf("the layout template rendered an empty path for version %s", version)
+	}
+	return dir, nil
+}
+
+// Get the language of the request by the URL or the domain
+func getRequestLang(r *http.Request) string {
+	switch GlobalConfig.I18nType {
+	case "location":
+		if lang := mux.Vars(r)["lang"]; lang != "" {
+			return lang
+		}
+	case "separate-domain":
+		return getLanguageFromDomainMap(r.Host)
+	case "domain":
+		return getLanguageFromDomain(r.Host)
+	}
+	return GlobalConfig.DefaultLanguage
+}
+
+// Serve the page of the version (e.g. cli/ for /documentation/v1.2/cli/) from the version directory or archive in the standalone mode.
+// Returns false if the standalone mode is disabled.
+func serveVersion(w http.ResponseWriter, r *http.Request, product *ProductType, version, page string) bool {
+	if !GlobalConfig.Standalone {
+		return false
+	}
+
+	dir, err := getVersionFilesPath(product, version, getRequestLang(r))
+	if err != nil {
+		log.Errorf("Can't get the directory of version %s: %s", version, err.Error())
+		notFoundHandler(w, r)
+		return true
+	}
+	if page == "" && !strings.HasSuffix(r.URL.Path, "/") {
+		// Relative links of the index page need the trailing slash
+		http.Redirect(w, r, r.URL.Path+"/", 301)
+		return true
+	}
+
+	versionFS, err := getVersionFileSystem(getStaticFS(), dir)
+	if err != nil {
+		log.Errorf("Can't open the files of version %s: %s", version, err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return true
+	}
+
+	log.Debugln(fmt.Sprintf("Serve %s from %s", r.URL.Path, dir))
+	versionRequest := r.Clone(r.Context())
+	versionRequest.URL.Path = "/" + strings.TrimPrefix(page, "/")
+	versionRequest.URL.RawPath = ""
+	// The 404 page is chosen by the original URL
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		notFoundHandler(w, r)
+	})
+	serveFiles(versionFS, notFound).ServeHTTP(w, versionRequest)
+	return true
+}
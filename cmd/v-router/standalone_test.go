@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStandaloneMode(t *testing.T) {
+	root, err := ioutil.TempDir("", "v-router-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for file, content := range map[string]string{
+		"v1.1.5/index.html":            "v1.1.5 index",
+		"v1.1.5/cli/index.html":        "v1.1.5 cli",
+		"v1.2.0-plus-fix1/index.html":  "v1.2.0+fix1 index",
+		"v1.2.0-plus-fix1/empty/.keep": "",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.PathStatic = root
+	GlobalConfig.Standalone = true
+
+	product := newTestProduct(t, &ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "v1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.5"}, {Name: "ea", Version: "v1.2.0+fix1"}}},
+	}})
+	router := newRouter()
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/documentation/v1/", http.StatusOK, "v1.1.5 index"},
+		{"/documentation/v1/cli/", http.StatusOK, "v1.1.5 cli"},
+		{"/documentation/v1.1.5/cli/", http.StatusOK, "v1.1.5 cli"},
+		{"/documentation/v1.2.0-plus-fix1/", http.StatusOK, "v1.2.0+fix1 index"},
+		{"/documentation/v1.2.0-plus-fix1", http.StatusMovedPermanently, ""},
+		{"/documentation/v1.1.5/missing.html", http.StatusNotFound, ""},
+		{"/documentation/v1.2.0-plus-fix1/empty/", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+		if recorder.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.path, test.code, recorder.Code)
+		}
+		if test.body != "" && recorder.Body.String() != test.body {
+			t.Errorf("%s: wrong body %q", test.path, recorder.Body.String())
+		}
+		if recorder.Header().Get("X-Accel-Redirect") != "" {
+			t.Errorf("%s: X-Accel-Redirect must not be set in the standalone mode", test.path)
+		}
+	}
+
+	// Pages can't be outside of the version directory
+	recorder := httptest.NewRecorder()
+	serveVersion(recorder, httptest.NewRequest("GET", "/documentation/v1.1.5/x", nil), product, "v1.1.5", "../v1.2.0-plus-fix1/index.html")
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a page outside of the version directory, got %d", recorder.Code)
+	}
+}
+
+func TestGetVersionFilesPath(t *testing.T) {
+	defer func() {
+		GlobalConfig.VersionsLayout = "{{ .VersionURL }}"
+		_ = initStandalone()
+	}()
+	product := &ProductType{Name: "deckhouse"}
+
+	tests := []struct {
+		layout string
+		path   string
+		err    bool
+	}{
+		{"{{ .VersionURL }}", "v1.2.3-plus-fix5", false},
+		{"{{ .Product }}/{{ .Lang }}/{{ .Version }}", "deckhouse/ru/v1.2.3+fix5", false},
+		{"../../{{ .VersionURL }}", "v1.2.3-plus-fix5", false},
+		{"", "", true},
+		{"{{ .Unknown }}", "", true},
+		{"{{ .VersionURL", "", true},
+	}
+	for _, test := range tests {
+		GlobalConfig.VersionsLayout = test.layout
+		err := initStandalone()
+		var path string
+		if err == nil {
+			path, err = getVersionFilesPath(product, "v1.2.3+fix5", "ru")
+		}
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error %v", test.layout, err)
+		}
+		if path != test.path {
+			t.Errorf("%q: expected %s, got %s", test.layout, test.path, path)
+		}
+	}
+}
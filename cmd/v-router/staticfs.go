@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Debug header with the static layer the file is served from
+const staticLayerHeader = "X-Static-Layer"
+
+// File system with static files and templates. If not set, the VROUTER_PATH_STATIC_OVERLAYS and VROUTER_PATH_STATIC directories are used.
+// It can be replaced with any file system, e.g. with embed.FS or with fstest.MapFS in tests.
+var staticFS fs.FS
+
+// Get the file system with static files and templates
+func getStaticFS() fs.FS {
+	if staticFS != nil {
+		return staticFS
+	}
+	if len(GlobalConfig.PathStaticOverlays) == 0 {
+		return os.DirFS(getRootFilesPath())
+	}
+
+	var overlay overlayFS
+	for _, dir := range GlobalConfig.PathStaticOverlays {
+		overlay = append(overlay, staticLayer{name: dir, fsys: os.DirFS(dir)})
+	}
+	return append(overlay, staticLayer{name: getRootFilesPath(), fsys: os.DirFS(getRootFilesPath())})
+}
+
+// Layer of the overlay file system
+type staticLayer struct {
+	name string // E.g. the directory of the layer
+	fsys fs.FS
+}
+
+// File system of several ordered layers, the first layer having the file wins.
+// E.g. per-language overrides, then shared assets.
+type overlayFS []staticLayer
+
+// Open the file of the first layer having it, implements fs.FS.
+// Other errors (e.g. permission errors) are returned, so the file of the next layer isn't served instead.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, layer := range o {
+		file, err := layer.fsys.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Get the name of the first layer having the file
+func (o overlayFS) getLayer(name string) string {
+	for _, layer := range o {
+		if _, err := fs.Stat(layer.fsys, name); err == nil {
+			return layer.name
+		}
+	}
+	return ""
+}
+
+// Set the debug header with the static layer having the file. The header is set only if the debug logging is enabled.
+func setStaticLayerHeader(w http.ResponseWriter, fsys fs.FS, name string) {
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	if overlay, ok := fsys.(overlayFS); ok {
+		if layer := overlay.getLayer(name); layer != "" {
+			w.Header().Set(staticLayerHeader, layer)
+		}
+	}
+}
+
+// Convert the URL path (e.g. /en/includes/menu.html) to the path in the file system (en/includes/menu.html).
+// The path is cleaned, so it can't point outside of the file system root.
+func toFSPath(urlPath string) string {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Check whether the file system has the directory
+func isFSDir(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.IsDir()
+}
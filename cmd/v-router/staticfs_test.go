@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+// File system failing to open any file
+type failingFS struct {
+	err error
+}
+
+func (f failingFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: f.err}
+}
+
+func TestToFSPath(t *testing.T) {
+	tests := map[string]string{
+		"/":                          ".",
+		"":                           ".",
+		"/en/includes/menu.html":     "en/includes/menu.html",
+		"en/includes/":               "en/includes",
+		"/../../etc/passwd":          "etc/passwd",
+		"/en/includes/../../../404":  "404",
+		"/en//includes/./menu.html/": "en/includes/menu.html",
+	}
+	for urlPath, expected := range tests {
+		if result := toFSPath(urlPath); result != expected {
+			t.Errorf("%q: expected %q, got %q", urlPath, expected, result)
+		}
+	}
+}
+
+func TestStaticFSTraversal(t *testing.T) {
+	GlobalConfig.I18nType = "domain"
+	// The file system root is en/ for the test, secret.html is outside of it
+	staticFS = fstest.MapFS{
+		"en/index.html":              {Data: []byte("index")},
+		"en/en/includes/menu.html":   {Data: []byte("menu")},
+		"secret.html":                {Data: []byte("secret")},
+		"en/en/includes/broken.html": {Data: []byte("{{ .Broken")},
+	}
+	defer func() { staticFS = nil }()
+
+	files, err := getVersionFileSystem(getStaticFS(), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/../secret.html", "/%2e%2e/secret.html", "/en/../../secret.html"} {
+		recorder := httptest.NewRecorder()
+		serveFiles(files, http.NotFoundHandler()).ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d %q", path, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Templates are read from the file system, broken templates don't stop the server
+	staticFS = fstest.MapFS{
+		"en/includes/menu.html":   {Data: []byte("menu")},
+		"en/includes/broken.html": {Data: []byte("{{ .Broken")},
+		"secret.html":             {Data: []byte("secret")},
+	}
+	tests := map[string]int{
+		"/includes/menu.html":            http.StatusOK,
+		"/includes/broken.html":          http.StatusInternalServerError,
+		"/includes/../../../secret.html": http.StatusInternalServerError,
+	}
+	for path, code := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/includes/menu.html", nil)
+		request.URL.Path = path
+		templateHandler(recorder, request)
+		if recorder.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, recorder.Code)
+		}
+		if recorder.Body.String() == "secret" {
+			t.Errorf("%s: a file outside of the language directory is served", path)
+		}
+	}
+}
+
+func TestOverlayFS(t *testing.T) {
+	GlobalConfig.I18nType = "domain"
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	staticFS = overlayFS{
+		{name: "overrides", fsys: fstest.MapFS{
+			"css/site.css":          {Data: []byte("override css")},
+			"en/includes/menu.html": {Data: []byte("override menu")},
+			"v1.1.5/cli/index.html": {Data: []byte("override cli")},
+			"en/404.html":           {Data: []byte("override 404")},
+			"docs/guide/.keep":      {Data: []byte("")},
+		}},
+		{name: "shared", fsys: fstest.MapFS{
+			"index.html":            {Data: []byte("shared index")},
+			"css/site.css":          {Data: []byte("shared css")},
+			"js/site.js":            {Data: []byte("shared js")},
+			"en/includes/menu.html": {Data: []byte("shared menu")},
+			"docs/guide/index.html": {Data: []byte("shared guide")},
+			"v1.1.5/index.html":     {Data: []byte("shared v1.1.5")},
+			"v1.1.5/cli/index.html": {Data: []byte("shared cli")},
+		}},
+	}
+	defer func() { staticFS = nil }()
+
+	tests := []struct {
+		path  string
+		code  int
+		body  string
+		layer string
+	}{
+		{"/css/site.css", http.StatusOK, "override css", "overrides"},
+		{"/js/site.js", http.StatusOK, "shared js", "shared"},
+		{"/", http.StatusOK, "shared index", "shared"},
+		// The directory of the first layer has no index.html, the index of the next layer is used
+		{"/docs/guide/", http.StatusOK, "shared guide", "shared"},
+		{"/missing.html", http.StatusNotFound, "override 404", "overrides"},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		serveFilesHandler(getStaticFS()).ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+		if recorder.Code != test.code || recorder.Body.String() != test.body {
+			t.Errorf("%s: expected %d %q, got %d %q", test.path, test.code, test.body, recorder.Code, recorder.Body.String())
+		}
+		if layer := recorder.Header().Get(staticLayerHeader); layer != test.layer {
+			t.Errorf("%s: expected the %q layer, got %q", test.path, test.layer, layer)
+		}
+	}
+
+	// Templates
+	recorder := httptest.NewRecorder()
+	templateHandler(recorder, httptest.NewRequest("GET", "/includes/menu.html", nil))
+	if recorder.Body.String() != "override menu" || recorder.Header().Get(staticLayerHeader) != "overrides" {
+		t.Errorf("Wrong template %q from the %q layer", recorder.Body.String(), recorder.Header().Get(staticLayerHeader))
+	}
+
+	// Version directories are overlaid too
+	versionFS, err := getVersionFileSystem(getStaticFS(), "v1.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"/": "shared v1.1.5", "/cli/": "override cli"} {
+		recorder := httptest.NewRecorder()
+		serveFiles(versionFS, http.NotFoundHandler()).ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Body.String() != expected {
+			t.Errorf("Version page %s: expected %q, got %q", path, expected, recorder.Body.String())
+		}
+	}
+
+	// The header is set only with the debug logging
+	log.SetLevel(log.InfoLevel)
+	recorder = httptest.NewRecorder()
+	serveFilesHandler(getStaticFS()).ServeHTTP(recorder, httptest.NewRequest("GET", "/css/site.css", nil))
+	if layer := recorder.Header().Get(staticLayerHeader); layer != "" {
+		t.Errorf("The %s header must not be set without the debug logging, got %q", staticLayerHeader, layer)
+	}
+}
+
+func TestOverlayFSErrors(t *testing.T) {
+	shared := staticLayer{name: "shared", fsys: fstest.MapFS{"css/site.css": {Data: []byte("shared css")}}}
+
+	// Missing files of a layer fall through to the next layer
+	overlay := overlayFS{{name: "overrides", fsys: failingFS{err: fs.ErrNotExist}}, shared}
+	if _, err := overlay.Open("css/site.css"); err != nil {
+		t.Errorf("Missing file must be opened from the next layer, got %v", err)
+	}
+
+	// Other errors are returned instead of serving the file of the next layer
+	overlay = overlayFS{{name: "overrides", fsys: failingFS{err: fs.ErrPermission}}, shared}
+	if _, err := overlay.Open("css/site.css"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected the permission error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"time"
+)
+
+// Support statuses of groups
+const (
+	supportStatusSupported  = "supported"
+	supportStatusDeprecated = "deprecated"
+	supportStatusEOL        = "eol"
+)
+
+var supportStatuses = []string{supportStatusSupported, supportStatusDeprecated, supportStatusEOL}
+
+// Date of the channels file, e.g. 2024-01-01 or 2024-01-01T10:00:00Z. Dates without the time are in UTC.
+type DateType struct {
+	time.Time
+}
+
+func parseDate(value string) (DateType, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return DateType{date}, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return DateType{}, fmt.Errorf("bad date %q, it must be like 2024-01-01 or 2024-01-01T10:00:00Z", value)
+	}
+	return DateType{date}, nil
+}
+
+func (d *DateType) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	date, err := parseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+func (d *DateType) UnmarshalYAML(value *yaml.Node) error {
+	date, err := parseDate(value.Value)
+	if err != nil {
+		// Other YAML timestamp formats, e.g. "2024-01-01 10:00:00"
+		if value.Decode(&d.Time) == nil {
+			return nil
+		}
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// Get the support status of the group at the specified time.
+// The explicit status is used unless the deprecation or the end-of-life date has come.
+func (g *ReleaseType) SupportStatusAt(now time.Time) string {
+	status := g.SupportStatus
+	if status == "" {
+		status = supportStatusSupported
+	}
+	if g.EOL != nil && !now.Before(g.EOL.Time) {
+		return supportStatusEOL
+	}
+	if g.Deprecated != nil && !now.Before(g.Deprecated.Time) && status == supportStatusSupported {
+		return supportStatusDeprecated
+	}
+	return status
+}
+
+// Get the end-of-life date of the group in the YYYY-MM-DD format, or an empty string if it is not set
+func (g *ReleaseType) EOLDate() string {
+	if g.EOL == nil {
+		return ""
+	}
+	return g.EOL.Format("2006-01-02")
+}
+
+// Get the group with the specified name
+func getReleaseGroup(releases *ReleasesStatusType, name string) *ReleaseType {
+	for i := range releases.Groups {
+		if releases.Groups[i].Name == name {
+			return &releases.Groups[i]
+		}
+	}
+	return nil
+}
+
+// Get the group the version belongs to.
+// The version can be a group name, a version of one of the channels, or any version of the group (e.g. v1.2.3 for the v1.2 group).
+func findGroupForVersion(releases *ReleasesStatusType, version string) *ReleaseType {
+	if group := getReleaseGroup(releases, version); group != nil {
+		return group
+	}
+	if _, groupName := getChannelAndGroupFromVersion(releases, version); groupName != "" {
+		if group := getReleaseGroup(releases, groupName); group != nil {
+			return group
+		}
+	}
+
+	parsedVersion, err := ParseVersion(version)
+	if err != nil {
+		return nil
+	}
+	for i := range releases.Groups {
+		group, err := ParseVersion(releases.Groups[i].Name)
+		if err != nil || group.Major != parsedVersion.Major {
+			continue
+		}
+		if group.Parts == 1 || group.Minor == parsedVersion.Minor {
+			return &releases.Groups[i]
+		}
+	}
+	return nil
+}
+
+// Get the version recommended instead of the deprecated group:
+// the default version of the replacement group, or of the newest supported group.
+func getRecommendedVersion(product *ProductType, releases *ReleasesStatusType, group *ReleaseType) *versionMenuItems {
+	now := time.Now()
+	replacement := ""
+	if group.Replacement != "" {
+		replacement = group.Replacement
+	} else {
+		for _, name := range getVisibleGroups(releases) {
+			if item := getReleaseGroup(releases, name); item != nil && item.SupportStatusAt(now) == supportStatusSupported {
+				replacement = name
+				break
+			}
+		}
+	}
+	if replacement == "" || replacement == group.Name {
+		return nil
+	}
+
+	version, err := getVersionFromGroup(product, releases, replacement)
+	if err != nil {
+		return nil
+	}
+	channel, _ := getChannelAndGroupFromVersion(releases, version)
+	return &versionMenuItems{
+		Group:         replacement,
+		Channel:       channel,
+		Version:       version,
+		VersionURL:    VersionToURL(version),
+		SupportStatus: supportStatusSupported,
+	}
+}
+
+// Set support status fields of the template data for the group of the current version
+func (m *templateDataType) setSupportStatus(product *ProductType, releases *ReleasesStatusType, group *ReleaseType) {
+	if group == nil {
+		return
+	}
+	m.SupportStatus = group.SupportStatusAt(time.Now())
+	m.IsDeprecated = m.SupportStatus != supportStatusSupported
+	m.EOLDate = group.EOLDate()
+	if m.IsDeprecated {
+		m.RecommendedVersion = getRecommendedVersion(product, releases, group)
+	}
+}
+
+// Set support status fields of the menu item for the group
+func (i *versionMenuItems) setSupportStatus(group *ReleaseType) {
+	if group == nil {
+		return
+	}
+	i.SupportStatus = group.SupportStatusAt(time.Now())
+	i.IsDeprecated = i.SupportStatus != supportStatusSupported
+	i.EOLDate = group.EOLDate()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSupportStatus(t *testing.T) {
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.I18nType = "domain"
+	GlobalConfig.ShowLatestChannel = false
+
+	releases, err := decodeReleasesStatus([]byte(`groups:
+ - name: v1.1
+   eol: 2020-01-01
+   channels:
+    - name: stable
+      version: v1.1.5
+ - name: v1.2
+   deprecated: 2020-01-01
+   eol: 2999-01-01
+   replacement: v1.3
+   channels:
+    - name: stable
+      version: v1.2.7
+ - name: v1.3
+   channels:
+    - name: stable
+      version: v1.3.2
+ - name: v1.4
+   supportStatus: deprecated
+   channels:
+    - name: alpha
+      version: v1.4.0
+`), "yaml", "channels.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := newTestProduct(t, releases)
+	product.DefaultGroup = "v1.3"
+
+	r := httptest.NewRequest("GET", "/includes/menu.html", nil)
+	r.Header.Set("x-original-uri", "/documentation/v1.2.7/index.html")
+	data := templateDataType{}
+	_ = data.getVersionMenuData(withProduct(r, product), releases)
+
+	if !data.IsDeprecated || data.SupportStatus != supportStatusDeprecated || data.EOLDate != "2999-01-01" {
+		t.Errorf("Wrong support status of the current version: %s, %v, %s", data.SupportStatus, data.IsDeprecated, data.EOLDate)
+	}
+	if data.RecommendedVersion == nil || data.RecommendedVersion.Version != "v1.3.2" {
+		t.Errorf("Wrong recommended version: %+v", data.RecommendedVersion)
+	}
+
+	statuses := make(map[string]string)
+	for _, item := range data.VersionItems[1:] {
+		statuses[item.Group] = item.SupportStatus
+	}
+	expected := map[string]string{"v1.1": supportStatusEOL, "v1.2": supportStatusDeprecated, "v1.3": supportStatusSupported, "v1.4": supportStatusDeprecated}
+	for group, status := range expected {
+		if statuses[group] != status {
+			t.Errorf("Wrong support status of the %s group menu items, expected %s, got %s", group, status, statuses[group])
+		}
+	}
+
+	// Without the explicit replacement, the newest supported group is recommended
+	if recommended := getRecommendedVersion(product, releases, getReleaseGroup(releases, "v1.1")); recommended == nil || recommended.Group != "v1.3" {
+		t.Errorf("Wrong recommended version for the v1.1 group: %+v", recommended)
+	}
+
+	// Hidden groups are not recommended
+	releases.Groups = append(releases.Groups, ReleaseType{Name: "v1.5", Hidden: true, Channels: []ChannelType{{Name: "stable", Version: "v1.5.0"}}})
+	if recommended := getRecommendedVersion(product, releases, getReleaseGroup(releases, "v1.1")); recommended == nil || recommended.Group != "v1.3" {
+		t.Errorf("Hidden group must not be recommended: %+v", recommended)
+	}
+}
+
+func TestSupportDates(t *testing.T) {
+	for format, data := range map[string]string{
+		"json": `{"groups": [{"name": "v1.1", "deprecated": "2020-01-01", "eol": "2020-06-01T12:00:00Z", "channels": [{"name": "stable", "version": "v1.1.5"}]}]}`,
+		"yaml": "groups:\n - name: v1.1\n   deprecated: 2020-01-01\n   eol: \"2020-06-01T12:00:00Z\"\n   channels:\n    - name: stable\n      version: v1.1.5\n",
+	} {
+		releases, err := decodeReleasesStatus([]byte(data), format, "channels."+format)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		group := releases.Groups[0]
+		if group.Deprecated == nil || group.Deprecated.Format("2006-01-02") != "2020-01-01" || group.EOLDate() != "2020-06-01" {
+			t.Errorf("%s: wrong dates %v, %v", format, group.Deprecated, group.EOL)
+		}
+	}
+
+	if _, err := decodeReleasesStatus([]byte(`{"groups": [{"name": "v1.1", "eol": "01.06.2020"}]}`), "json", "channels.json"); err == nil || !strings.Contains(err.Error(), "channels.json") {
+		t.Errorf("expected an error for a bad date with the file name, got %v", err)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem found by the configuration or channels file validation
+type validationProblem struct {
+	Source  string `json:"source"`         // Checked file or environment variable
+	Path    string `json:"path,omitempty"` // Location of the problem in the channels data, e.g. "groups[1].channels[0]"
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"` // The problem doesn't make the configuration invalid
+}
+
+type validationResultType struct {
+	Valid    bool                `json:"valid"`
+	Problems []validationProblem `json:"problems"`
+}
+
+func (p validationProblem) String() string {
+	message := p.Message
+	if p.Warning {
+		message = "warning: " + message
+	}
+	if p.Path != "" {
+		return fmt.Sprintf("%s: %s: %s", p.Source, p.Path, message)
+	}
+	return fmt.Sprintf("%s: %s", p.Source, message)
+}
+
+// Check whether there are problems other than warnings
+func hasErrors(problems []validationProblem) bool {
+	for _, problem := range problems {
+		if !problem.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Languages, e.g. "en" or "zh-CN"
+var languageRegexp = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]+)?$`)
+
+// Group names used in URLs, e.g. "v1" or "v1.2"
+var groupNameRegexp = regexp.MustCompile(`^v[0-9]+(\.[0-9]+)?$`)
+
+// Check the configuration. Returns all the problems found.
+func validateConfig() (problems []validationProblem) {
+	addProblem := func(source, format string, args ...interface{}) {
+		problems = append(problems, validationProblem{Source: source, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(GlobalConfig.Channels) == 0 {
+		addProblem("VROUTER_CHANNELS", "Channel list is empty. Use the VROUTER_CHANNELS environment variable to specify channels.")
+	}
+	if !contains(i18nTypes, GlobalConfig.I18nType) {
+		addProblem("VROUTER_I18N_TYPE", "Unknown localization method specified (%s). It must be one of the following: %s.", GlobalConfig.I18nType, strings.Join(i18nTypes, ", "))
+	}
+	if !contains(channelURLGroups, GlobalConfig.ChannelURLGroup) {
+		addProblem("VROUTER_CHANNEL_URL_GROUP", "Unknown group for channel URLs specified (%s). It must be one of the following: %s.", GlobalConfig.ChannelURLGroup, strings.Join(channelURLGroups, ", "))
+	}
+	if err := initProxy(); err != nil {
+		addProblem("VROUTER_PROXY_UPSTREAM", "Bad upstream URL template: %s.", err.Error())
+	} else if _, err := getVersionUpstream(&ProductType{Name: "default"}, &ReleasesStatusType{}, "v1.2.3"); err != nil {
+		addProblem("VROUTER_PROXY_UPSTREAM", "Bad upstream URL template: %s.", err.Error())
+	}
+	for _, overlay := range GlobalConfig.PathStaticOverlays {
+		if fileInfo, err := os.Stat(overlay); err != nil || !fileInfo.IsDir() {
+			addProblem("VROUTER_PATH_STATIC_OVERLAYS", "Static overlay '%s' is not a directory.", overlay)
+		}
+	}
+	// The layout is used only in the standalone mode
+	err := initStandalone()
+	if err == nil {
+		_, err = getVersionFilesPath(&ProductType{Name: "default"}, "v1.2.3", GlobalConfig.DefaultLanguage)
+	}
+	if err != nil && GlobalConfig.Standalone {
+		addProblem("VROUTER_VERSIONS_LAYOUT", "Bad layout of version directories: %s.", err.Error())
+	}
+	if len(GlobalConfig.Languages) == 0 {
+		addProblem("VROUTER_LANGUAGES", "Language list is empty. Use the VROUTER_LANGUAGES environment variable to specify languages.")
+	}
+	for _, lang := range GlobalConfig.Languages {
+		if !languageRegexp.MatchString(lang) {
+			addProblem("VROUTER_LANGUAGES", "Bad language %q, it must be like 'en' or 'zh-CN'.", lang)
+		}
+	}
+	if !contains(GlobalConfig.Languages, GlobalConfig.DefaultLanguage) {
+		addProblem("VROUTER_DEFAULT_LANGUAGE", "The default language (%s) is not in the language list (%s).", GlobalConfig.DefaultLanguage, strings.Join(GlobalConfig.Languages, ", "))
+	}
+	if GlobalConfig.I18nType == "separate-domain" {
+		if err := getDomainMap(); err != nil {
+			addProblem("VROUTER_DOMAIN_MAP", err.Error())
+		}
+		for lang := range DomainMap {
+			if !contains(GlobalConfig.Languages, lang) {
+				addProblem("VROUTER_DOMAIN_MAP", "Unknown language %q in the domain map, it must be one of the following: %s.", lang, strings.Join(GlobalConfig.Languages, ", "))
+			}
+		}
+	}
+
+	for _, product := range Products {
+		problems = append(problems, validateProductConfig(product)...)
+	}
+	return
+}
+
+// Get the languages templates are served for. In the separate-domain mode, these are the languages of the domain map
+// and the default language (used for unknown domains).
+func getTemplateLanguages() []string {
+	if GlobalConfig.I18nType != "separate-domain" || len(DomainMap) == 0 {
+		return GlobalConfig.Languages
+	}
+	languages := []string{GlobalConfig.DefaultLanguage}
+	for lang := range DomainMap {
+		if !contains(languages, lang) {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages[1:])
+	return languages
+}
+
+// Check the product configuration: the templates directory and the channels file
+func validateProductConfig(product *ProductType) (problems []validationProblem) {
+	tplsSource, channelsSource := "VROUTER_PATH_TPLS", "VROUTER_PATH_CHANNELS_FILE"
+	if GlobalConfig.PathProductsFile != "" {
+		tplsSource = fmt.Sprintf("%s: %s product", GlobalConfig.PathProductsFile, product.Name)
+		channelsSource = tplsSource
+	}
+	addProblem := func(source, format string, args ...interface{}) {
+		problems = append(problems, validationProblem{Source: source, Message: fmt.Sprintf(format, args...)})
+	}
+	addWarning := func(source, format string, args ...interface{}) {
+		problems = append(problems, validationProblem{Source: source, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	// Check template directory
+	if GlobalConfig.I18nType == "domain" {
+		if fi, err := fs.Stat(getStaticFS(), toFSPath(product.PathTpls)); err == nil {
+			if !fi.IsDir() {
+				addProblem(tplsSource, "Incorrect path for templates. The '%s%s' path is not a directory", getRootFilesPath(), product.PathTpls)
+			}
+		} else {
+			addProblem(tplsSource, "Template directory '%s%s' doesn't exist", getRootFilesPath(), product.PathTpls)
+		}
+	}
+
+	// Templates are read from the language directories, e.g. en/includes. Missing ones are reported as warnings,
+	// as the router can run without them.
+	for _, lang := range getTemplateLanguages() {
+		tplsPath := path.Join(lang, toFSPath(product.PathTpls))
+		if fi, err := fs.Stat(getStaticFS(), tplsPath); err == nil {
+			if !fi.IsDir() {
+				addWarning(tplsSource, "Incorrect path for templates. The '%s/%s' path is not a directory", getRootFilesPath(), tplsPath)
+			}
+		} else {
+			addWarning(tplsSource, "Template directory '%s/%s' doesn't exist", getRootFilesPath(), tplsPath)
+		}
+	}
+
+	// Check channels file
+	if product.PathChannelsFile == "" {
+		if product.ChannelsData == "" {
+			addProblem(channelsSource, "No channels data specified. Use the VROUTER_PATH_CHANNELS_FILE or VROUTER_CHANNELS_DATA environment variable to specify it.")
+		}
+	} else if isURL(product.PathChannelsFile) {
+		if _, err := url.Parse(product.PathChannelsFile); err != nil {
+			addProblem(channelsSource, "Channels file URL '%s' is incorrect (%s)", product.PathChannelsFile, err.Error())
+		}
+	} else if _, err := os.Stat(product.PathChannelsFile); err != nil {
+		if os.IsNotExist(err) {
+			addProblem(channelsSource, "Channels file '%s' doesn't exist", product.PathChannelsFile)
+		} else {
+			addProblem(channelsSource, "Channels file '%s' access error", product.PathChannelsFile)
+		}
+	}
+	return
+}
+
+// Check a channels file or a directory with channels files.
+// If staticPath is not empty, check that every version has a directory in it.
+func validateChannelsPath(path, staticPath string) (problems []validationProblem) {
+	var files []string
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return append(problems, validationProblem{Source: path, Message: err.Error()})
+	}
+	if fileInfo.IsDir() {
+		items, err := ioutil.ReadDir(path)
+		if err != nil {
+			return append(problems, validationProblem{Source: path, Message: err.Error()})
+		}
+		for _, item := range items {
+			if !item.IsDir() && !strings.HasPrefix(item.Name(), ".") && getChannelsFormat(item.Name(), "") != "" {
+				files = append(files, filepath.Join(path, item.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return append(problems, validationProblem{Source: path, Message: "no channels files found in the directory"})
+		}
+	} else {
+		files = append(files, path)
+	}
+
+	merged := &ReleasesStatusType{}
+	decoded := make(map[string]*ReleasesStatusType)
+	// Source file of every group-channel pair, to find duplicates across files
+	channelFiles := make(map[string]string)
+	for _, file := range files {
+		releases, err := decodeChannelsFileStrict(file)
+		if err != nil {
+			problems = append(problems, validationProblem{Source: file, Message: err.Error()})
+			continue
+		}
+		decoded[file] = releases
+		mergeReleasesStatus(merged, releases)
+
+		for _, group := range releases.Groups {
+			for _, channel := range group.Channels {
+				key := group.Name + "-" + channel.Name
+				if previous, ok := channelFiles[key]; ok && previous != file {
+					problems = append(problems, validationProblem{Source: file, Message: fmt.Sprintf("channel %s of the %s group is also defined in %s", channel.Name, group.Name, previous)})
+				}
+				channelFiles[key] = file
+			}
+		}
+	}
+
+	// Channel names are checked against the merged list, as it can be declared in another file
+	for _, file := range files {
+		if releases, ok := decoded[file]; ok {
+			problems = append(problems, validateReleasesStatus(releases, getChannelsListReverseStability(merged), file)...)
+		}
+	}
+
+	// Replacement groups are checked against the merged data, as they can be declared in another file
+	for _, group := range merged.Groups {
+		if group.Replacement != "" && getReleaseGroup(merged, group.Replacement) == nil {
+			problems = append(problems, validationProblem{Source: path, Message: fmt.Sprintf("replacement group %q of the %q group doesn't exist", group.Replacement, group.Name)})
+		}
+	}
+
+	// The global fallback order is checked against the channel list, as it can be declared in the channels file
+	for _, channel := range GlobalConfig.FallbackChannels {
+		if !contains(getChannelsListReverseStability(merged), channel) {
+			problems = append(problems, validationProblem{Source: "VROUTER_FALLBACK_CHANNELS", Message: fmt.Sprintf("unknown channel %q in the fallback order", channel)})
+		}
+	}
+
+	if staticPath != "" {
+		problems = append(problems, validateVersionDirectories(merged, staticPath)...)
+	}
+	return
+}
+
+// Read and decode a channels file without the fallback to the last valid data
+func decodeChannelsFileStrict(path string) (*ReleasesStatusType, error) {
+	releases := &ReleasesStatusType{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch getChannelsFormat(path, "") {
+	case "json":
+		err = json.Unmarshal(data, releases)
+	case "yaml":
+		err = yaml.Unmarshal(data, releases)
+	default:
+		err = fmt.Errorf("unknown channels file format, use the .json, .yaml or .yml extension")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't decode channels file (%s)", err.Error())
+	}
+	return releases, nil
+}
+
+// Check the decoded channels data: duplicates, unknown channels, empty versions and bad group names
+func validateReleasesStatus(releases *ReleasesStatusType, channels []string, source string) (problems []validationProblem) {
+	addProblem := func(path, format string, args ...interface{}) {
+		problems = append(problems, validationProblem{Source: source, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	knownChannels := append([]string{}, channels...)
+	if GlobalConfig.ShowLatestChannel {
+		knownChannels = append(knownChannels, "latest")
+	}
+
+	for i, channel := range releases.Channels {
+		if contains(releases.Channels[:i], channel) {
+			addProblem(fmt.Sprintf("channels[%d]", i), "duplicate channel %q in the channel list", channel)
+		}
+	}
+
+	aliases := make([]string, 0, len(releases.Aliases))
+	for alias := range releases.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for i, alias := range aliases {
+		aliasPath := fmt.Sprintf("aliases.%s", alias)
+		channel := releases.Aliases[alias]
+		switch {
+		case alias == "" || strings.Contains(alias, "/"):
+			addProblem(aliasPath, "bad channel alias %q", alias)
+		case containsFold(knownChannels, alias):
+			addProblem(aliasPath, "alias %q matches the channel name", alias)
+		case containsFold(aliases[:i], alias):
+			addProblem(aliasPath, "duplicate alias %q (aliases are case-insensitive)", alias)
+		}
+		if !contains(knownChannels, channel) {
+			addProblem(aliasPath, "alias %q refers to the unknown channel %q, it must be one of the following: %s", alias, channel, strings.Join(knownChannels, ", "))
+		}
+	}
+
+	groups := make(map[string]bool)
+	for i, group := range releases.Groups {
+		groupPath := fmt.Sprintf("groups[%d]", i)
+		switch {
+		case group.Name == "":
+			addProblem(groupPath, "empty group name")
+		case !groupNameRegexp.MatchString(group.Name):
+			addProblem(groupPath, "bad group name %q, it must be like 'v1' or 'v1.2' (group URLs start with 'v')", group.Name)
+		case groups[group.Name]:
+			addProblem(groupPath, "duplicate group %q", group.Name)
+		}
+		groups[group.Name] = true
+
+		if len(group.Channels) == 0 {
+			addProblem(groupPath, "group %q has no channels", group.Name)
+		}
+		if group.SupportStatus != "" && !contains(supportStatuses, group.SupportStatus) {
+			addProblem(groupPath, "unknown support status %q, it must be one of the following: %s", group.SupportStatus, strings.Join(supportStatuses, ", "))
+		}
+		for j, badge := range group.Badges {
+			if strings.TrimSpace(badge) == "" {
+				addProblem(fmt.Sprintf("%s.badges[%d]", groupPath, j), "empty badge of the %q group", group.Name)
+			}
+		}
+		if group.DefaultChannel != "" && !contains(knownChannels, group.DefaultChannel) {
+			addProblem(groupPath, "unknown default channel %q of the %q group, it must be one of the following: %s", group.DefaultChannel, group.Name, strings.Join(knownChannels, ", "))
+		}
+		for j, channel := range group.FallbackChannels {
+			if !contains(knownChannels, channel) {
+				addProblem(fmt.Sprintf("%s.fallbackChannels[%d]", groupPath, j), "unknown channel %q in the fallback order, it must be one of the following: %s", channel, strings.Join(knownChannels, ", "))
+			}
+		}
+		if group.Deprecated != nil && group.EOL != nil && group.EOL.Before(group.Deprecated.Time) {
+			addProblem(groupPath, "end-of-life date of the %q group is before its deprecation date", group.Name)
+		}
+
+		groupChannels := make(map[string]bool)
+		for j, channel := range group.Channels {
+			channelPath := fmt.Sprintf("%s.channels[%d]", groupPath, j)
+			switch {
+			case channel.Name == "":
+				addProblem(channelPath, "empty channel name")
+			case !contains(knownChannels, channel.Name):
+				addProblem(channelPath, "unknown channel %q, it must be one of the following: %s", channel.Name, strings.Join(knownChannels, ", "))
+			case groupChannels[channel.Name]:
+				addProblem(channelPath, "duplicate channel %q in the %q group", channel.Name, group.Name)
+			}
+			groupChannels[channel.Name] = true
+
+			if channel.Version == "" {
+				addProblem(channelPath, "empty version for the %q channel", channel.Name)
+			}
+			if channel.Upstream != "" {
+				if upstream, err := url.Parse(channel.Upstream); err != nil || upstream.Scheme == "" || upstream.Host == "" {
+					addProblem(channelPath, "bad upstream URL %q of the %q channel", channel.Upstream, channel.Name)
+				}
+			}
+
+			for k, item := range channel.History {
+				if item.Version == "" {
+					addProblem(fmt.Sprintf("%s.history[%d]", channelPath, k), "empty version in the %q channel history", channel.Name)
+				}
+				if item.Promoted.IsZero() {
+					addProblem(fmt.Sprintf("%s.history[%d]", channelPath, k), "empty promotion time in the %q channel history", channel.Name)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Check that every version referenced in the channels data has a directory or an archive with static files
+func validateVersionDirectories(releases *ReleasesStatusType, staticPath string) (problems []validationProblem) {
+	staticFiles := os.DirFS(staticPath)
+	checked := make(map[string]bool)
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			if channel.Version == "" || checked[channel.Version] {
+				continue
+			}
+			checked[channel.Version] = true
+
+			versionPath := filepath.Join(staticPath, VersionToURL(channel.Version))
+			if !hasVersionFiles(staticFiles, VersionToURL(channel.Version)) {
+				problems = append(problems, validationProblem{
+					Source:  "VROUTER_PATH_STATIC",
+					Message: fmt.Sprintf("directory or archive '%s' for the %s version (%s-%s) doesn't exist", versionPath, channel.Version, group.Name, channel.Name),
+				})
+			}
+		}
+	}
+	return
+}
+
+// The 'validate' command. Checks the configuration and the channels file, prints the problems found.
+// Returns the process exit code.
+func validateCommand(args []string, output io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(output)
+	channelsPath := flags.String("channels-file", "", "channels file or directory with channels files to check (channels files of all the products by default)")
+	staticPath := flags.String("static", GlobalConfig.PathStatic, "directory with version directories to check, use an empty value to skip the check")
+	format := flags.String("format", "text", "output format (text|json)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var channelsPaths []string
+	if *channelsPath != "" {
+		channelsPaths = append(channelsPaths, *channelsPath)
+		if GlobalConfig.PathProductsFile == "" {
+			getDefaultProduct().PathChannelsFile = *channelsPath
+		}
+	} else {
+		for _, product := range Products {
+			// Remote channels files are not checked offline
+			if product.PathChannelsFile != "" && !isURL(product.PathChannelsFile) {
+				channelsPaths = append(channelsPaths, product.PathChannelsFile)
+			}
+		}
+	}
+
+	problems := validateConfig()
+	for _, path := range channelsPaths {
+		problems = append(problems, validateChannelsPath(path, *staticPath)...)
+	}
+
+	switch *format {
+	case "json":
+		result := validationResultType{Valid: !hasErrors(problems), Problems: problems}
+		if result.Problems == nil {
+			result.Problems = []validationProblem{}
+		}
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(result)
+	default:
+		for _, problem := range problems {
+			fmt.Fprintln(output, problem.String())
+		}
+		if !hasErrors(problems) {
+			fmt.Fprintln(output, "OK")
+		}
+	}
+
+	if hasErrors(problems) {
+		return 1
+	}
+	return 0
+}
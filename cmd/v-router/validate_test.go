@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateCommand(t *testing.T) {
+	dir := t.TempDir()
+	GlobalConfig.I18nType = "location"
+	GlobalConfig.Channels = []string{"rock-solid", "stable", "ea", "beta", "alpha"}
+	GlobalConfig.ShowLatestChannel = false
+	GlobalConfig.ChannelURLGroup = "newest"
+	GlobalConfig.PathProductsFile = ""
+	Products = nil
+	staticFS = fstest.MapFS{
+		"en/includes/version-menu.html": {Data: []byte("")},
+		"ru/includes/version-menu.html": {Data: []byte("")},
+	}
+	defer func() { staticFS = nil }()
+
+	channelsFile := filepath.Join(dir, "channels.yaml")
+	content := `aliases:
+  early-access: ea
+  Stable: stable
+  nightly: unknown
+groups:
+ - name: v1.1
+   channels:
+    - name: stable
+      version: v1.1.5
+    - name: stable
+      version: v1.1.4
+    - name: nightly
+      version: v1.1.6
+ - name: v1.1
+   channels:
+    - name: ea
+      version: ""
+ - name: latest-group
+   channels:
+    - name: alpha
+      version: v1.3.0
+`
+	if err := ioutil.WriteFile(channelsFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "static", "v1.1.5"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	output := &bytes.Buffer{}
+	code := validateCommand([]string{"-channels-file", channelsFile, "-static", filepath.Join(dir, "static"), "-format", "json"}, output)
+	if code != 1 {
+		t.Errorf("Wrong exit code, expected 1, got %d", code)
+	}
+
+	var result validationResultType
+	if err := json.Unmarshal(output.Bytes(), &result); err != nil {
+		t.Fatalf("Can't decode output: %s (%s)", err.Error(), output.String())
+	}
+
+	expected := map[string]bool{
+		"groups[0].channels[1]": false, // Duplicate channel
+		"groups[0].channels[2]": false, // Unknown channel
+		"groups[1]":             false, // Duplicate group
+		"groups[1].channels[0]": false, // Empty version
+		"groups[2]":             false, // Bad group name
+		"aliases.Stable":        false, // Alias matches the channel name
+		"aliases.nightly":       false, // Alias of an unknown channel
+	}
+	staticProblems := 0
+	for _, problem := range result.Problems {
+		if _, ok := expected[problem.Path]; ok {
+			expected[problem.Path] = true
+		}
+		if problem.Source == "VROUTER_PATH_STATIC" {
+			staticProblems++
+		}
+	}
+	for path, found := range expected {
+		if !found {
+			t.Errorf("Problem at %s is not reported: %s", path, output.String())
+		}
+	}
+	// Directories for v1.1.4, v1.1.6 and v1.3.0 are missing
+	if staticProblems != 3 {
+		t.Errorf("Wrong number of missing version directories, expected 3, got %d", staticProblems)
+	}
+
+	if err := ioutil.WriteFile(channelsFile, []byte("groups:\n - name: v1.1\n   channels:\n    - name: stable\n      version: v1.1.5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output.Reset()
+	if code := validateCommand([]string{"-channels-file", channelsFile, "-static", filepath.Join(dir, "static")}, output); code != 0 {
+		t.Errorf("Valid channels file is reported as invalid: %s", output.String())
+	}
+}
+
+func TestValidateTemplateDirectories(t *testing.T) {
+	restoreConfig(t)
+	staticFS = fstest.MapFS{
+		"en/includes/version-menu.html": {Data: []byte("")},
+		"ru/includes":                   {Data: []byte("not a directory")},
+	}
+	defer func() {
+		staticFS = nil
+		DomainMap = nil
+	}()
+	product := &ProductType{Name: "docs", PathTpls: "/includes", ChannelsData: "groups: []"}
+
+	tests := []struct {
+		i18nType  string
+		domainMap map[string]string
+		errors    int
+		warnings  int
+	}{
+		{"location", nil, 0, 1},
+		// The domain mode also requires the root templates directory
+		{"domain", nil, 1, 1},
+		// Only the languages of the domain map and the default language are checked
+		{"separate-domain", map[string]string{"en": "example.com"}, 0, 0},
+		{"separate-domain", map[string]string{"en": "example.com", "de": "example.de"}, 0, 1},
+	}
+	for _, test := range tests {
+		GlobalConfig.I18nType, DomainMap = test.i18nType, test.domainMap
+		problems := validateProductConfig(product)
+		warnings := 0
+		for _, problem := range problems {
+			if problem.Warning {
+				warnings++
+			}
+		}
+		if len(problems)-warnings != test.errors || warnings != test.warnings {
+			t.Errorf("%s %v: expected %d errors and %d warnings, got %v", test.i18nType, test.domainMap, test.errors, test.warnings, problems)
+		}
+		if hasErrors(problems) != (test.errors > 0) {
+			t.Errorf("%s %v: hasErrors() is %v", test.i18nType, test.domainMap, hasErrors(problems))
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version of a product or a group name, e.g. "v1.2.3-rc.1+fix5", "1.2" or "v1".
+// Both the v-prefixed and the bare forms are accepted.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Parts      int    // Number of specified numeric parts, e.g. 2 for "v1.2"
+	PreRelease string // E.g. "rc.1" for "v1.2.3-rc.1"
+	Build      string // Build metadata, e.g. "fix5" for "v1.2.3+fix5"
+	Original   string
+}
+
+var versionRegexp = regexp.MustCompile(`^[vV]?([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z._-]+))?$`)
+var numberRegexp = regexp.MustCompile(`[0-9]+|[^0-9]+`)
+
+// Parse version string
+func ParseVersion(version string) (*Version, error) {
+	res := versionRegexp.FindStringSubmatch(strings.TrimSpace(version))
+	if res == nil {
+		return nil, fmt.Errorf("can't parse version %s", version)
+	}
+
+	result := &Version{PreRelease: res[4], Build: res[5], Original: version}
+	for i, part := range []*int{&result.Major, &result.Minor, &result.Patch} {
+		if res[i+1] == "" {
+			break
+		}
+		value, err := strconv.Atoi(res[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("can't parse version %s (%s)", version, err.Error())
+		}
+		*part = value
+		result.Parts++
+	}
+	return result, nil
+}
+
+// Compare versions. Returns -1 if v is older than other, 1 if v is newer, and 0 if they are equal.
+// Unlike semver, the build metadata is also compared, so "1.1.23+fix50" is newer than "1.1.23+fix25".
+func (v *Version) Compare(other *Version) int {
+	for _, pair := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] != pair[1] {
+			return compareInts(pair[0], pair[1])
+		}
+	}
+
+	// A pre-release version is older than the corresponding release
+	if v.PreRelease != other.PreRelease {
+		if v.PreRelease == "" {
+			return 1
+		}
+		if other.PreRelease == "" {
+			return -1
+		}
+		return comparePreRelease(v.PreRelease, other.PreRelease)
+	}
+
+	// A build with metadata (e.g. a fix) is newer than the build without it
+	if v.Build != other.Build {
+		if v.Build == "" {
+			return -1
+		}
+		if other.Build == "" {
+			return 1
+		}
+		return compareNatural(v.Build, other.Build)
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Compare pre-release parts according to semver, e.g. "alpha.1" < "alpha.2" < "beta"
+func comparePreRelease(a, b string) int {
+	aItems := strings.Split(a, ".")
+	bItems := strings.Split(b, ".")
+	for i := 0; i < len(aItems) && i < len(bItems); i++ {
+		if aItems[i] == bItems[i] {
+			continue
+		}
+		aNum, aErr := strconv.Atoi(aItems[i])
+		bNum, bErr := strconv.Atoi(bItems[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return compareInts(aNum, bNum)
+		case aErr == nil:
+			// Numeric identifiers have lower precedence
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(aItems[i], bItems[i])
+		}
+	}
+	return compareInts(len(aItems), len(bItems))
+}
+
+// Compare strings treating digit sequences as numbers, e.g. "fix9" < "fix10"
+func compareNatural(a, b string) int {
+	aItems := numberRegexp.FindAllString(a, -1)
+	bItems := numberRegexp.FindAllString(b, -1)
+	for i := 0; i < len(aItems) && i < len(bItems); i++ {
+		if aItems[i] == bItems[i] {
+			continue
+		}
+		aNum, aErr := strconv.Atoi(aItems[i])
+		bNum, bErr := strconv.Atoi(bItems[i])
+		if aErr == nil && bErr == nil {
+			return compareInts(aNum, bNum)
+		}
+		return strings.Compare(aItems[i], bItems[i])
+	}
+	return compareInts(len(aItems), len(bItems))
+}
+
+// Compare version strings. A string that can't be parsed as a version is considered older than any version.
+func compareVersionStrings(a, b string) int {
+	aVersion, aErr := ParseVersion(a)
+	bVersion, bErr := ParseVersion(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		if result := aVersion.Compare(bVersion); result != 0 {
+			return result
+		}
+		return strings.Compare(a, b)
+	case aErr == nil:
+		return 1
+	case bErr == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// Sort version strings from the newest to the oldest
+func sortVersionsDescending(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersionStrings(versions[i], versions[j]) > 0
+	})
+}
+
+// Get the newest version among all the groups and channels
+func getNewestVersion(releases *ReleasesStatusType) (result string) {
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			if result == "" || compareVersionStrings(channel.Version, result) > 0 {
+				result = channel.Version
+			}
+		}
+	}
+	return
+}
+
+// Version range from the URL, e.g. "v1.2.x", "v1.x" or "~1.2"
+type VersionRange struct {
+	Major int
+	Minor int // -1 if any minor version matches
+	Patch int // The lowest matching patch version, e.g. 3 for "~1.2.3"
+}
+
+var versionWildcardRegexp = regexp.MustCompile(`^[vV]?([0-9]+)(?:\.([0-9]+))?(?:\.[xX*])+$`)
+var versionTildeRegexp = regexp.MustCompile(`^~[vV]?([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?$`)
+
+// Parse version range. Wildcards ("v1.2.x", "1.x", "v1.2.*") and tilde ranges ("~1.2", "~1.2.3") are supported.
+// Returns an error for a usual version, e.g. "v1.2.3".
+func ParseVersionRange(versionRange string) (*VersionRange, error) {
+	res := versionWildcardRegexp.FindStringSubmatch(versionRange)
+	if res == nil {
+		res = versionTildeRegexp.FindStringSubmatch(versionRange)
+	}
+	if res == nil {
+		return nil, fmt.Errorf("can't parse version range %s", versionRange)
+	}
+
+	result := &VersionRange{Minor: -1}
+	for i, part := range []*int{&result.Major, &result.Minor, &result.Patch} {
+		if i+1 >= len(res) || res[i+1] == "" {
+			break
+		}
+		value, err := strconv.Atoi(res[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("can't parse version range %s (%s)", versionRange, err.Error())
+		}
+		*part = value
+	}
+	return result, nil
+}
+
+// Check whether the version is in the range
+func (vr *VersionRange) Contains(v *Version) bool {
+	if v.Major != vr.Major {
+		return false
+	}
+	if vr.Minor < 0 {
+		return true
+	}
+	return v.Minor == vr.Minor && v.Patch >= vr.Patch
+}
+
+// Get the newest version in the range among all the channels. Hidden groups are routable, so their versions are used too.
+func getNewestVersionInRange(releases *ReleasesStatusType, versionRange *VersionRange) (result string) {
+	for _, group := range releases.Groups {
+		for _, channel := range group.Channels {
+			version, err := ParseVersion(channel.Version)
+			if err != nil || !versionRange.Contains(version) {
+				continue
+			}
+			if result == "" || compareVersionStrings(channel.Version, result) > 0 {
+				result = channel.Version
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Version
+	}{
+		{"v1", Version{Major: 1, Parts: 1}},
+		{"1.2", Version{Major: 1, Minor: 2, Parts: 2}},
+		{"v1.1.23+fix50", Version{Major: 1, Minor: 1, Patch: 23, Parts: 3, Build: "fix50"}},
+		{"1.2.27-rc.1+fix3", Version{Major: 1, Minor: 2, Patch: 27, Parts: 3, PreRelease: "rc.1", Build: "fix3"}},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.input)
+		if err != nil {
+			t.Errorf("Can't parse %s: %s", test.input, err.Error())
+			continue
+		}
+		test.expected.Original = test.input
+		if !reflect.DeepEqual(*version, test.expected) {
+			t.Errorf("Wrong result for %s: got %+v, want %+v", test.input, *version, test.expected)
+		}
+	}
+
+	for _, input := range []string{"", "latest", "v1.2.3.4", "1.x"} {
+		if _, err := ParseVersion(input); err == nil {
+			t.Errorf("Invalid version %q parsed without an error", input)
+		}
+	}
+}
+
+func TestCompareVersionStrings(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.10", "1.9", 1},
+		{"v1", "v2", -1},
+		{"v1.2", "1.2", 1}, // Equal versions are ordered as strings
+		{"1.1.23+fix50", "1.1.23+fix25", 1},
+		{"1.1.23+fix9", "1.1.23+fix10", -1},
+		{"1.1.21", "1.1.21+fix40", -1},
+		{"1.2.0-rc.1", "1.2.0", -1},
+		{"1.2.0-alpha.2", "1.2.0-alpha.10", -1},
+		{"1.2.0-alpha", "1.2.0-alpha.1", -1},
+		{"latest", "v1", -1},
+	}
+
+	for _, test := range tests {
+		if result := compareVersionStrings(test.a, test.b); result != test.expected {
+			t.Errorf("compareVersionStrings(%s, %s) = %d, want %d", test.a, test.b, result, test.expected)
+		}
+	}
+}
+
+func TestGetGroupsAndNewestVersion(t *testing.T) {
+	releases := ReleasesStatusType{Groups: []ReleaseType{
+		{Name: "1.9", Channels: []ChannelType{{Name: "stable", Version: "1.9.3+fix2"}}},
+		{Name: "1.10", Channels: []ChannelType{{Name: "stable", Version: "1.10.1"}, {Name: "alpha", Version: "1.10.2+fix1"}}},
+		{Name: "1.2", Channels: []ChannelType{{Name: "stable", Version: "1.2.7"}}},
+	}}
+
+	if groups := getGroups(&releases); !reflect.DeepEqual(groups, []string{"1.10", "1.9", "1.2"}) {
+		t.Errorf("Wrong group order: %v", groups)
+	}
+	if version := getNewestVersion(&releases); version != "1.10.2+fix1" {
+		t.Errorf("Wrong newest version, expected 1.10.2+fix1, got %s", version)
+	}
+}
+
+func TestVersionRanges(t *testing.T) {
+	releases := &ReleasesStatusType{
+		Groups: []ReleaseType{
+			{Name: "v1.1", Channels: []ChannelType{{Name: "stable", Version: "v1.1.9"}}},
+			{Name: "v1.2", Channels: []ChannelType{{Name: "stable", Version: "v1.2.3"}, {Name: "ea", Version: "v1.2.10+fix2"}, {Name: "alpha", Version: "v1.2.10"}}},
+			{Name: "v1.3", Channels: []ChannelType{{Name: "alpha", Version: "v1.3.0-rc.1"}}},
+			{Name: "v2.0", Channels: []ChannelType{{Name: "alpha", Version: "v2.0.1"}}},
+			{Name: "v3.0", Hidden: true, Channels: []ChannelType{{Name: "alpha", Version: "v3.0.0"}}},
+		},
+	}
+
+	tests := []struct {
+		input, expected string
+	}{
+		{"v1.2.x", "v1.2.10+fix2"},
+		{"1.1.*", "v1.1.9"},
+		{"v1.x", "v1.3.0-rc.1"},
+		{"v2.x.x", "v2.0.1"},
+		{"~1.2", "v1.2.10+fix2"},
+		{"~1.2.11", ""},
+		{"~v1", "v1.3.0-rc.1"},
+		{"v3.x", "v3.0.0"},
+		{"v4.x", ""},
+	}
+	for _, test := range tests {
+		versionRange, err := ParseVersionRange(test.input)
+		if err != nil {
+			t.Errorf("Can't parse %s: %s", test.input, err.Error())
+			continue
+		}
+		if version := getNewestVersionInRange(releases, versionRange); version != test.expected {
+			t.Errorf("Wrong version for the %s range: got %q, want %q", test.input, version, test.expected)
+		}
+	}
+
+	for _, input := range []string{"v1.2.3", "v1.2", "x", "v1.x.2", "~", "stable"} {
+		if _, err := ParseVersionRange(input); err == nil {
+			t.Errorf("Invalid version range %q parsed without an error", input)
+		}
+	}
+}